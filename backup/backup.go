@@ -0,0 +1,352 @@
+// Package backup packs the whole keep folder into a single .tar.gz archive and
+// restores it back.
+//
+// Every archive starts with a manifest.json entry listing the format version
+// of the .kps files and the size and sha256 checksum of each of them, so an
+// archive is fully validated before anything in the store is touched.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/common"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+const ManifestName = "manifest.json"
+
+var (
+	ErrNoManifest       = errors.New("archive has no manifest")
+	ErrChecksumMismatch = errors.New("archive file doesn't match its checksum")
+	ErrUnsupported      = errors.New("archive format is newer than this keep supports")
+)
+
+type Mode string
+
+const (
+	// Replace makes the store an exact copy of the archive: groups that aren't
+	// in the archive are removed.
+	Replace Mode = "replace"
+	// Merge adds the groups missing from the store and, for the groups that
+	// exist on both sides, the notes missing from the store.
+	Merge Mode = "merge"
+)
+
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Partial archives hold just some files of the store. Replacing from them
+	// only overwrites those files instead of removing everything else.
+	Partial bool   `json:"partial,omitempty"`
+	Files   []File `json:"files"`
+}
+
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Create writes a snapshot of the keep folder into w. All .kps files are
+// locked while they are copied, so the archive is consistent even if another
+// keep process tries to write at the same time.
+func Create(w io.Writer) (Manifest, error) {
+	return create(w, nil)
+}
+
+// CreateOnly is like Create but the archive holds only the named .kps files.
+func CreateOnly(w io.Writer, names ...string) (Manifest, error) {
+	only := make(map[string]bool, len(names))
+	for _, name := range names {
+		only[name] = true
+	}
+	return create(w, only)
+}
+
+func create(w io.Writer, only map[string]bool) (Manifest, error) {
+	files, release, err := notes.LockStore(false)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer release()
+
+	manifest := Manifest{
+		FormatVersion: notes.FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Partial:       only != nil,
+	}
+	var contents [][]byte
+
+	for _, sf := range files {
+		if only != nil && !only[sf.Name] {
+			continue
+		}
+		content, err := io.ReadAll(sf.File)
+		if err != nil {
+			return manifest, fmt.Errorf("unable to read %s: %w", sf.Name, err)
+		}
+		contents = append(contents, content)
+		manifest.Files = append(manifest.Files, File{
+			Name:   sf.Name,
+			Size:   int64(len(content)),
+			SHA256: checksum(content),
+		})
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	m, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeEntry(tw, ManifestName, m, manifest.CreatedAt); err != nil {
+		return manifest, err
+	}
+	for i, f := range manifest.Files {
+		if err := writeEntry(tw, f.Name, contents[i], manifest.CreatedAt); err != nil {
+			return manifest, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	return manifest, gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(content)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// Archive is a fully read and validated backup.
+type Archive struct {
+	Manifest Manifest
+	Files    map[string][]byte
+}
+
+// Open reads the archive from r and validates it against its manifest: every
+// listed file must be present with the right size and checksum, and nothing
+// else may be in it.
+func Open(r io.Reader) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a keep backup: %w", err)
+	}
+	defer gz.Close()
+
+	var (
+		tr       = tar.NewReader(gz)
+		files    = map[string][]byte{}
+		manifest *Manifest
+	)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", hdr.Name, err)
+		}
+		if hdr.Name == ManifestName {
+			manifest = new(Manifest)
+			if err := json.Unmarshal(content, manifest); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			continue
+		}
+		if !isStoreFile(hdr.Name) {
+			return nil, fmt.Errorf("unexpected file %q in archive", hdr.Name)
+		}
+		files[hdr.Name] = content
+	}
+
+	if manifest == nil {
+		return nil, ErrNoManifest
+	}
+	if manifest.FormatVersion > notes.FormatVersion {
+		return nil, fmt.Errorf("%w: version %v", ErrUnsupported, manifest.FormatVersion)
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, f := range manifest.Files {
+		content, ok := files[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed in the manifest but missing from the archive", f.Name)
+		}
+		if int64(len(content)) != f.Size || checksum(content) != f.SHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, f.Name)
+		}
+		listed[f.Name] = true
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, fmt.Errorf("%s is in the archive but not listed in the manifest", name)
+		}
+	}
+
+	return &Archive{Manifest: *manifest, Files: files}, nil
+}
+
+// Restore validates the archive read from r and applies it to the store.
+func Restore(r io.Reader, mode Mode) (Manifest, error) {
+	a, err := Open(r)
+	if err != nil {
+		return Manifest{}, err
+	}
+	switch mode {
+	case Replace:
+		return a.Manifest, a.replace()
+	case Merge:
+		return a.Manifest, a.merge()
+	default:
+		return a.Manifest, fmt.Errorf("unknown restore mode %q", mode)
+	}
+}
+
+func (a *Archive) replace() error {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		return err
+	}
+
+	files, release, err := notes.LockStore(true)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// files already in the store are rewritten in place through the locked
+	// handles, so a keep process waiting on one of them reads the new content
+	for _, sf := range files {
+		content, ok := a.Files[sf.Name]
+		if !ok {
+			if a.Manifest.Partial {
+				continue
+			}
+			if err := os.Remove(path.Join(kfp, sf.Name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := overwrite(sf.File, content); err != nil {
+			return fmt.Errorf("unable to restore %s: %w", sf.Name, err)
+		}
+	}
+
+	for _, f := range a.Manifest.Files {
+		if utils.DoesFileExists(path.Join(kfp, f.Name)) {
+			continue
+		}
+		if err := writeStoreFile(kfp, f.Name, a.Files[f.Name]); err != nil {
+			return fmt.Errorf("unable to restore %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (a *Archive) merge() error {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		return err
+	}
+
+	for _, f := range a.Manifest.Files {
+		target := path.Join(kfp, f.Name)
+		content := a.Files[f.Name]
+
+		if !utils.DoesFileExists(target) {
+			if err := writeStoreFile(kfp, f.Name, content); err != nil {
+				return fmt.Errorf("unable to restore %s: %w", f.Name, err)
+			}
+			continue
+		}
+
+		// the info file only holds counters of the current store
+		if f.Name == common.INFO_FILE_PATH {
+			continue
+		}
+
+		header, archived, err := notes.DecodeGroup(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("unable to read %s from archive: %w", f.Name, err)
+		}
+		if _, err := notes.MergeNotes(strings.TrimSuffix(f.Name, ".kps"), header, archived); err != nil {
+			return fmt.Errorf("unable to merge %s: %w", f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func overwrite(f *os.File, content []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.WriteAt(content, 0)
+	return err
+}
+
+// isStoreFile reports whether name can be written into the keep folder, i.e.
+// it is the info file or the file of a group, which can't point anywhere else.
+func isStoreFile(name string) bool {
+	if name == common.INFO_FILE_PATH {
+		return true
+	}
+	groupName, ok := strings.CutSuffix(name, ".kps")
+	if !ok {
+		return false
+	}
+	_, err := notes.ParseGroupName(groupName)
+	return err == nil
+}
+
+// writeStoreFile writes a file of the store that doesn't exist yet, along with
+// the directories of the groups it is a subgroup of.
+func writeStoreFile(kfp, name string, content []byte) error {
+	target := path.Join(kfp, name)
+	if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, 0600)
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/backup"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func setupStore(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return kfp
+}
+
+func readGroup(t *testing.T, group string) []notes.Note {
+	t.Helper()
+	ch, err := notes.ReadAllNotes(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []notes.Note
+	for n := range ch {
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	kfp := setupStore(t)
+
+	if _, err := notes.NewNoteFile("books", "books to read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("books", "Programming Language Pragmatics"); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	manifest, err := backup.Create(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.FormatVersion != notes.FormatVersion {
+		t.Fatalf("expected format version %v, got %v", notes.FormatVersion, manifest.FormatVersion)
+	}
+	if len(manifest.Files) != 1 || manifest.Files[0].Name != "books.kps" {
+		t.Fatalf("unexpected manifest files: %+v", manifest.Files)
+	}
+
+	t.Run("Replace", func(t *testing.T) {
+		if err := notes.DeleteGroup("books"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := notes.NewNoteFile("movies", "movies to watch"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backup.Restore(bytes.NewReader(archive.Bytes()), backup.Replace); err != nil {
+			t.Fatal(err)
+		}
+
+		if utils.DoesFileExists(path.Join(kfp, "movies.kps")) {
+			t.Fatal("group created after the backup wasn't removed")
+		}
+		if got := readGroup(t, "books"); len(got) != 1 {
+			t.Fatalf("expected 1 note, got %v", len(got))
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		if err := notes.AddNote("books", "Crafting Interpreters"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := notes.NewNoteFile("movies", "movies to watch"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := backup.Restore(bytes.NewReader(archive.Bytes()), backup.Merge); err != nil {
+			t.Fatal(err)
+		}
+
+		if !utils.DoesFileExists(path.Join(kfp, "movies.kps")) {
+			t.Fatal("merge removed a group missing from the archive")
+		}
+		// the archived note is already there, so nothing is duplicated
+		if got := readGroup(t, "books"); len(got) != 2 {
+			t.Fatalf("expected 2 notes, got %v", len(got))
+		}
+	})
+}
+
+func TestOpenRejectsTamperedArchive(t *testing.T) {
+	setupStore(t)
+
+	if _, err := notes.NewNoteFile("books", "books to read"); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if _, err := backup.Create(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite the archive flipping a byte of books.kps
+	gz, err := gzip.NewReader(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		tr       = tar.NewReader(gz)
+		tampered bytes.Buffer
+		gw       = gzip.NewWriter(&tampered)
+		tw       = tar.NewWriter(gw)
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name == "books.kps" {
+			content[0] ^= 0xff
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+
+	_, err = backup.Open(&tampered)
+	if !errors.Is(err, backup.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,458 @@
+package configs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/common"
+	"github.com/DavidEsdrs/keep/groupname"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// Keys of the settings of the config file.
+const (
+	DefaultGroup  = "default_group"
+	StorePath     = "store_path"
+	DateFormat    = "display.date_format"
+	TimeFormat    = "display.time_format"
+	Theme         = "display.theme"
+	Color         = "display.color"
+	Sort          = "display.sort"
+	ColorLow      = "colors.low"
+	ColorNormal   = "colors.normal"
+	ColorHigh     = "colors.high"
+	ColorUrgent   = "colors.urgent"
+	ConfirmDelete = "confirm.delete"
+)
+
+// Setting is a setting of the config file.
+type Setting struct {
+	Key   string
+	Usage string
+	Bool  bool // whether its value is true or false
+
+	value func(profile string) string // default value
+	check func(string) error
+}
+
+// Env returns the environment variable overriding the setting, e.g.
+// KEEP_DISPLAY_THEME for display.theme.
+func (s Setting) Env() string {
+	return "KEEP_" + strings.ToUpper(strings.ReplaceAll(s.Key, ".", "_"))
+}
+
+// Settings are all the settings of the config file, in the order they are
+// listed.
+var Settings = []Setting{
+	{Key: DefaultGroup, Usage: "group of the notes created without one", value: constant(common.DEFAULT_KEEP_FILE_PATH), check: checkGroup},
+	{Key: StorePath, Usage: "folder the groups are stored in", value: defaultStorePath, check: checkNotEmpty},
+	{Key: DateFormat, Usage: "layout of the dates notes were created on, see https://pkg.go.dev/time#Layout", value: constant("01/02/2006"), check: checkLayout},
+	{Key: TimeFormat, Usage: "layout of the times notes were created at today", value: constant(time.Kitchen), check: checkLayout},
+	{Key: Theme, Usage: "colors fitting a dark or a light terminal", value: constant("dark"), check: oneOf("dark", "light")},
+	{Key: Color, Usage: "when to write colors: auto (to terminals only), always or never", value: constant("auto"), check: oneOf("auto", "always", "never")},
+	{Key: Sort, Usage: "order of the notes after the pinned ones: id or priority", value: constant("id"), check: oneOf("id", "priority")},
+	{Key: ColorLow, Usage: "color of the notes of low priority", value: constant("blue"), check: checkColor},
+	{Key: ColorNormal, Usage: "color of the notes of normal priority", value: constant("cyan"), check: checkColor},
+	{Key: ColorHigh, Usage: "color of the notes of high priority", value: constant("yellow"), check: checkColor},
+	{Key: ColorUrgent, Usage: "color of the notes of urgent priority", value: constant("red"), check: checkColor},
+	{Key: ConfirmDelete, Usage: "ask before deleting a group", Bool: true, value: constant("false"), check: checkBool},
+}
+
+// Lookup returns the setting of the given key.
+func Lookup(key string) (Setting, error) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return Setting{}, fmt.Errorf("unknown setting %q, see `keep config list`", key)
+}
+
+// Check validates a value of the setting.
+func (s Setting) Check(value string) error {
+	if err := s.check(value); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", s.Key, value, err)
+	}
+	return nil
+}
+
+// Config holds the value of every setting, taken from the environment
+// variable of the setting, else from the config file, else its default.
+type Config struct {
+	values  map[string]string
+	sources map[string]string
+}
+
+// Get returns the value of a setting.
+func (c *Config) Get(key string) string {
+	return c.values[key]
+}
+
+// Bool returns the value of a setting that is true or false.
+func (c *Config) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.values[key])
+	return b
+}
+
+// Source tells where the value of a setting comes from: "default", the path
+// of the config file or an environment variable.
+func (c *Config) Source(key string) string {
+	return c.sources[key]
+}
+
+// Path returns the location of the config file: KEEP_CONFIG or, by default,
+// keep/config.toml under the user config dir, e.g. $XDG_CONFIG_HOME.
+func Path() (string, error) {
+	if p := os.Getenv("KEEP_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keep", "config.toml"), nil
+}
+
+// Load reads the config file, which doesn't need to exist, the file of the
+// profile and the environment. Invalid values are reported all at once and
+// left to their default, so the returned config is always usable.
+//
+// The store_path of the config file is the one of the default profile: other
+// profiles are stored in their default store unless their own file says
+// otherwise.
+func Load(profile string) (*Config, error) {
+	c := &Config{values: map[string]string{}, sources: map[string]string{}}
+	for _, s := range Settings {
+		c.values[s.Key] = s.value(profile)
+		c.sources[s.Key] = "default"
+	}
+
+	var errs []error
+	files := []string{}
+	p, err := Path()
+	if err != nil {
+		return c, err
+	}
+	files = append(files, p)
+	if profile != utils.DefaultProfile {
+		p, err := ProfilePath(profile)
+		if err != nil {
+			return c, err
+		}
+		files = append(files, p)
+	}
+
+	for i, p := range files {
+		content, err := os.ReadFile(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return c, err
+		}
+		entries, fileErrs := parseFile(content)
+		for _, err := range fileErrs {
+			errs = append(errs, fmt.Errorf("%s:%w", p, err))
+		}
+		for _, e := range entries {
+			if e.key == StorePath && i == 0 && len(files) > 1 {
+				continue
+			}
+			if err := c.set(e.key, e.value, p); err != nil {
+				errs = append(errs, fmt.Errorf("%s:%v: %w", p, e.line, err))
+			}
+		}
+	}
+
+	for _, s := range Settings {
+		if value, ok := os.LookupEnv(s.Env()); ok {
+			if err := c.set(s.Key, value, s.Env()); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Env(), err))
+			}
+		}
+	}
+	return c, errors.Join(errs...)
+}
+
+func (c *Config) set(key, value, source string) error {
+	s, err := Lookup(key)
+	if err != nil {
+		return err
+	}
+	if err := s.Check(value); err != nil {
+		return err
+	}
+	c.values[key] = value
+	c.sources[key] = source
+	return nil
+}
+
+// Set validates the value of a setting and writes it into the config file,
+// creating the file if needed. The rest of the file, comments included, is
+// left as it is.
+func Set(key, value string) error {
+	p, err := Path()
+	if err != nil {
+		return err
+	}
+	return setIn(p, key, value)
+}
+
+// SetInProfile is like Set, writing into the file of a profile instead. The
+// file of the default profile is the config file.
+func SetInProfile(profile, key, value string) error {
+	if profile == utils.DefaultProfile {
+		return Set(key, value)
+	}
+	p, err := ProfilePath(profile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err != nil {
+		return fmt.Errorf("unknown profile %s, see `keep profile list`", profile)
+	}
+	return setIn(p, key, value)
+}
+
+func setIn(p, key, value string) error {
+	s, err := Lookup(key)
+	if err != nil {
+		return err
+	}
+	if err := s.Check(value); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if _, errs := parseFile(content); len(errs) > 0 {
+		return fmt.Errorf("%s:%w", p, errs[0])
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, setLine(content, s, value), 0644)
+}
+
+// entry is a key set in the config file, with the line it is set on.
+type entry struct {
+	key, value string
+	line       int
+}
+
+// parseFile parses the subset of TOML the config file is written in: tables
+// of keys set to strings or booleans. It returns the entries by their dotted
+// key, in the order of the file, and an error per invalid line.
+func parseFile(content []byte) ([]entry, []error) {
+	var (
+		entries []entry
+		errs    []error
+		seen    = map[string]bool{}
+	)
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for i := 1; scanner.Scan(); i++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "["); ok {
+			name, _, _ = strings.Cut(name, "#")
+			name, ok = strings.CutSuffix(strings.TrimSpace(name), "]")
+			if !ok || strings.TrimSpace(name) == "" {
+				errs = append(errs, fmt.Errorf("%v: invalid table %q", i, line))
+				continue
+			}
+			table = strings.TrimSpace(name)
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("%v: expected key = value, got %q", i, line))
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if table != "" {
+			key = table + "." + key
+		}
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %s: %w", i, key, err))
+			continue
+		}
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("%v: %s is set twice", i, key))
+			continue
+		}
+		seen[key] = true
+		entries = append(entries, entry{key: key, value: value, line: i})
+	}
+	return entries, errs
+}
+
+// parseValue parses a quoted string, a literal string or a bare value such as
+// true, with an optional comment after it.
+func parseValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after the value", rest)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		value, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after the value", rest)
+		}
+		return value, nil
+	}
+	value, _, _ := strings.Cut(s, "#")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return value, nil
+}
+
+// setLine returns the content of the config file with the setting set to
+// value: the line of the setting is replaced, or one is added to its table.
+func setLine(content []byte, s Setting, value string) []byte {
+	table, name := "", s.Key
+	if i := strings.LastIndex(s.Key, "."); i >= 0 {
+		table, name = s.Key[:i], s.Key[i+1:]
+	}
+	line := name + " = " + strconv.Quote(value)
+	if s.Bool {
+		line = name + " = " + value
+	}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(content) == 0 {
+		lines = nil
+	}
+
+	current, insertAt := "", -1
+	if table == "" {
+		insertAt = 0
+	}
+	for i, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "[") {
+			current = strings.TrimSpace(strings.Trim(strings.SplitN(trimmed, "#", 2)[0], "[] "))
+			if current == table {
+				insertAt = i + 1
+			}
+			continue
+		}
+		if current != table || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if key, _, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(key) == name {
+			indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+			lines[i] = indent + line
+			return []byte(strings.Join(lines, "\n") + "\n")
+		}
+		insertAt = i + 1
+	}
+
+	switch {
+	case insertAt >= 0:
+		lines = append(lines[:insertAt], append([]string{line}, lines[insertAt:]...)...)
+	case len(lines) > 0:
+		lines = append(lines, "", "["+table+"]", line)
+	default:
+		lines = []string{"[" + table + "]", line}
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// Keys returns the keys of all settings, sorted.
+func Keys() []string {
+	keys := make([]string, len(Settings))
+	for i, s := range Settings {
+		keys[i] = s.Key
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func constant(value string) func(string) string {
+	return func(string) string { return value }
+}
+
+func defaultStorePath(profile string) string {
+	p, err := utils.DefaultStorePath(profile)
+	if err != nil {
+		return ".keep"
+	}
+	return p
+}
+
+func oneOf(values ...string) func(string) error {
+	return func(s string) error {
+		for _, v := range values {
+			if s == v {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected %s", strings.Join(values, " or "))
+	}
+}
+
+func checkNotEmpty(s string) error {
+	if strings.TrimSpace(s) == "" {
+		return fmt.Errorf("it can't be empty")
+	}
+	return nil
+}
+
+// checkGroup accepts the names groups can be created with, as the default
+// group is created along with its first note, and the default group itself.
+func checkGroup(s string) error {
+	if s == groupname.Default {
+		return nil
+	}
+	return groupname.Check(s)
+}
+
+func checkLayout(s string) error {
+	// any time but the one of the layouts, so layouts change when formatted
+	sample := time.Date(2001, 11, 22, 23, 11, 33, 0, time.UTC)
+	if sample.Format(s) == s {
+		return fmt.Errorf("expected a layout holding parts of 2006-01-02 15:04:05, such as 02/01/2006")
+	}
+	return nil
+}
+
+func checkColor(s string) error {
+	_, err := utils.ParseColor(s)
+	return err
+}
+
+func checkBool(s string) error {
+	if _, err := strconv.ParseBool(s); err != nil {
+		return fmt.Errorf("expected true or false")
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package configs_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/configs"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func setupConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv("KEEP_CONFIG", p)
+	if content != "" {
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestLoad(t *testing.T) {
+	p := setupConfig(t, `# my settings
+default_group = "inbox"
+store_path = '~/my notes' # literal string
+
+[display]
+theme = "light"
+sort = "priority"
+
+[confirm]
+delete = true
+`)
+	t.Setenv("KEEP_DISPLAY_SORT", "id")
+
+	c, err := configs.Load(utils.DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		configs.DefaultGroup: "inbox",
+		configs.StorePath:    "~/my notes",
+		configs.Theme:        "light",
+		configs.Sort:         "id",
+		configs.DateFormat:   "01/02/2006",
+	} {
+		if got := c.Get(key); got != want {
+			t.Fatalf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+	if !c.Bool(configs.ConfirmDelete) {
+		t.Fatal("expected confirm.delete to be true")
+	}
+	for key, want := range map[string]string{
+		configs.Theme:      p,
+		configs.Sort:       "KEEP_DISPLAY_SORT",
+		configs.DateFormat: "default",
+	} {
+		if got := c.Source(key); got != want {
+			t.Fatalf("%s: expected source %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	setupConfig(t, `theme = "dark"
+[display]
+theme = "blue"
+sort = "priority"
+date_format = nope
+[colors
+`)
+	t.Setenv("KEEP_COLORS_LOW", "purple")
+
+	c, err := configs.Load(utils.DefaultProfile)
+	if err == nil {
+		t.Fatal("expected invalid settings to fail")
+	}
+	for _, want := range []string{
+		`:1: unknown setting "theme"`,
+		`:3: invalid display.theme "blue": expected dark or light`,
+		`:5: invalid display.date_format "nope"`,
+		`:6: invalid table "[colors"`,
+		`KEEP_COLORS_LOW: invalid colors.low "purple"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	// the valid settings are kept, the invalid ones left to their default
+	if c.Get(configs.Sort) != "priority" || c.Get(configs.Theme) != "dark" || c.Get(configs.ColorLow) != "blue" {
+		t.Fatalf("unexpected settings %q %q %q", c.Get(configs.Sort), c.Get(configs.Theme), c.Get(configs.ColorLow))
+	}
+}
+
+func TestSet(t *testing.T) {
+	p := setupConfig(t, `# my settings
+store_path = "~/notes"
+
+[display]
+# dark terminal
+theme = "dark"
+`)
+
+	for _, s := range [][2]string{
+		{configs.Theme, "light"},
+		{configs.Sort, "priority"},
+		{configs.DefaultGroup, "inbox"},
+		{configs.ConfirmDelete, "true"},
+	} {
+		if err := configs.Set(s[0], s[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := configs.Set(configs.Theme, "blue"); err == nil {
+		t.Fatal("expected an invalid value to fail")
+	}
+	for _, group := range []string{"info", "../books", "books!", "work//ideas"} {
+		if err := configs.Set(configs.DefaultGroup, group); err == nil {
+			t.Fatalf("expected default group %q to fail", group)
+		}
+	}
+	if err := configs.Set("display.colour", "red"); err == nil {
+		t.Fatal("expected an unknown setting to fail")
+	}
+
+	content, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# my settings
+store_path = "~/notes"
+default_group = "inbox"
+
+[display]
+# dark terminal
+theme = "light"
+sort = "priority"
+
+[confirm]
+delete = true
+`
+	if string(content) != want {
+		t.Fatalf("unexpected config file:\n%s\nexpected:\n%s", content, want)
+	}
+
+	c, err := configs.Load(utils.DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Get(configs.Theme) != "light" || c.Get(configs.DefaultGroup) != "inbox" || !c.Bool(configs.ConfirmDelete) {
+		t.Fatal("expected the settings set to be loaded")
+	}
+}
+
+func TestSetCreatesFile(t *testing.T) {
+	p := setupConfig(t, "")
+	t.Setenv("KEEP_CONFIG", filepath.Join(filepath.Dir(p), "keep", "config.toml"))
+	if err := configs.Set(configs.TimeFormat, "15:04"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(p), "keep", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "[display]\ntime_format = \"15:04\"\n" {
+		t.Fatalf("unexpected config file %q", content)
+	}
+}
@@ -36,8 +36,11 @@ func (n *NotesInfo) Remove() {
 }
 
 func (n *NotesInfo) Save() error {
-	dir, _ := os.UserHomeDir()
-	f, err := os.OpenFile(path.Join(dir, ".keep", common.INFO_FILE_PATH), os.O_WRONLY, 0700)
+	dir, err := utils.GetKeepFilePath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path.Join(dir, common.INFO_FILE_PATH), os.O_WRONLY, 0700)
 	if err != nil {
 		return fmt.Errorf("something went wrong with the info file! did you delete it?: %w", err)
 	}
@@ -45,21 +48,21 @@ func (n *NotesInfo) Save() error {
 
 	_, err = f.WriteString(n.String())
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("%v%v", "something went wrong while writing into the info file!: %w", err))
+		return fmt.Errorf("something went wrong while writing into the info file!: %w", err)
 	}
 	return nil
 }
 
-// get singleton
-func GetDefaultGroupState() *NotesInfo {
+// get singleton, creating the info file when needed
+func GetDefaultGroupState() (*NotesInfo, error) {
 	if info == nil {
 		inf, err := CreateInfoFile(common.INFO_FILE_PATH)
 		if err != nil {
-			panic("unable to manage default note file")
+			return nil, fmt.Errorf("unable to manage default note file: %w", err)
 		}
 		info = inf
 	}
-	return info
+	return info, nil
 }
 
 func CreateInfoFile(filename string) (*NotesInfo, error) {
@@ -76,15 +79,17 @@ func CreateInfoFile(filename string) (*NotesInfo, error) {
 		if err != nil {
 			return &notesInfo, fmt.Errorf("unable to open info file: %w", err)
 		}
+		defer f.Close()
 		notesInfo, err = ParseInfoContent(f)
 		if err != nil {
 			return &notesInfo, fmt.Errorf("unable to parse info file content: %w", err)
 		}
 	} else {
-		info, err = Create(targetFile)
+		created, err := Create(targetFile)
 		if err != nil {
 			return &notesInfo, err
 		}
+		notesInfo = *created
 	}
 
 	// assign to the global object
@@ -97,7 +102,7 @@ func ParseInfoContent(f *os.File) (NotesInfo, error) {
 	var notesInfo NotesInfo
 
 	if err := binary.Read(f, binary.BigEndian, &notesInfo); err != nil {
-		return notesInfo, nil
+		return notesInfo, err
 	}
 
 	return notesInfo, nil
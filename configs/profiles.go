@@ -0,0 +1,209 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// MaxProfileNameLength is the longest a profile name can be.
+const MaxProfileNameLength = 32
+
+// profilesDir returns the folder holding the file of each profile, next to
+// the config file.
+func profilesDir() (string, error) {
+	p, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(p), "profiles"), nil
+}
+
+// ProfilePath returns the file of a profile, holding the settings it
+// overrides.
+func ProfilePath(profile string) (string, error) {
+	if err := checkProfile(profile); err != nil {
+		return "", err
+	}
+	dir, err := profilesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, profile+".toml"), nil
+}
+
+func checkProfile(name string) error {
+	if name == "" || len(name) > MaxProfileNameLength {
+		return fmt.Errorf("invalid profile name %q: expected 1 to %v characters", name, MaxProfileNameLength)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid profile name %q: expected letters, digits, dashes and underscores", name)
+		}
+	}
+	return nil
+}
+
+// Profiles returns the names of all profiles, the default one included,
+// sorted.
+func Profiles() ([]string, error) {
+	dir, err := profilesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	names := []string{utils.DefaultProfile}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".toml"); ok && !e.IsDir() && checkProfile(name) == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ProfileExists reports whether there is a profile of the given name.
+func ProfileExists(name string) (bool, error) {
+	names, err := Profiles()
+	if err != nil {
+		return false, err
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// activeProfilePath returns the file remembering the profile in use.
+func activeProfilePath() (string, error) {
+	p, err := Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(p), "profile"), nil
+}
+
+// ActiveProfile returns the profile in use: the one of KEEP_PROFILE, else the
+// one last chosen with UseProfile, else the default one.
+func ActiveProfile() (string, error) {
+	if name := os.Getenv("KEEP_PROFILE"); name != "" {
+		return name, nil
+	}
+	p, err := activeProfilePath()
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return utils.DefaultProfile, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if name := strings.TrimSpace(string(content)); name != "" {
+		return name, nil
+	}
+	return utils.DefaultProfile, nil
+}
+
+// UseProfile makes a profile the one in use from now on.
+func UseProfile(name string) error {
+	ok, err := ProfileExists(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("unknown profile %s, see `keep profile list`", name)
+	}
+	p, err := activeProfilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(name+"\n"), 0644)
+}
+
+// AddProfile creates a profile storing its notes in storePath, or in its
+// default store when storePath is empty. The store is created along with it.
+func AddProfile(name, storePath string) error {
+	if name == utils.DefaultProfile {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+	p, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if storePath != "" {
+		if err := settingOf(StorePath).Check(storePath); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("profile %s already exists", name)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content := []byte(fmt.Sprintf("# settings of the %s profile, overriding the ones of config.toml\n", name))
+	if storePath != "" {
+		content = setLine(content, settingOf(StorePath), storePath)
+	}
+	if _, err := f.Write(content); err != nil {
+		return err
+	}
+
+	store := defaultStorePath(name)
+	if storePath != "" {
+		store = utils.ExpandHome(storePath)
+	}
+	return os.MkdirAll(store, 0755)
+}
+
+// RemoveProfile removes a profile, leaving its notes where they are. When it
+// was in use, the default profile is used from now on.
+func RemoveProfile(name string) error {
+	if name == utils.DefaultProfile {
+		return fmt.Errorf("the default profile can't be removed")
+	}
+	p, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unknown profile %s, see `keep profile list`", name)
+	} else if err != nil {
+		return err
+	}
+
+	active, err := activeProfilePath()
+	if err != nil {
+		return err
+	}
+	if content, err := os.ReadFile(active); err == nil && strings.TrimSpace(string(content)) == name {
+		return os.Remove(active)
+	}
+	return nil
+}
+
+func settingOf(key string) Setting {
+	s, _ := Lookup(key)
+	return s
+}
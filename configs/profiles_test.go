@@ -0,0 +1,119 @@
+package configs_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/configs"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func TestProfiles(t *testing.T) {
+	setupConfig(t, `store_path = "/notes"
+[display]
+theme = "light"
+sort = "priority"
+`)
+	t.Setenv("KEEP_PROFILE", "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if err := configs.AddProfile("work", ""); err != nil {
+		t.Fatal(err)
+	}
+	personal := filepath.Join(home, "personal")
+	if err := configs.AddProfile("personal", personal); err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range []string{filepath.Join(home, ".keep-work"), personal} {
+		if _, err := os.Stat(store); err != nil {
+			t.Fatalf("store of the profile not created: %v", err)
+		}
+	}
+	for _, name := range []string{"work", utils.DefaultProfile, "a/b", ""} {
+		if err := configs.AddProfile(name, ""); err == nil {
+			t.Fatalf("expected adding profile %q to fail", name)
+		}
+	}
+	names, err := configs.Profiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{utils.DefaultProfile, "personal", "work"}) {
+		t.Fatalf("unexpected profiles %v", names)
+	}
+
+	if err := configs.SetInProfile("work", configs.Theme, "dark"); err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.SetInProfile("nope", configs.Theme, "dark"); err == nil {
+		t.Fatal("expected setting in an unknown profile to fail")
+	}
+
+	// profiles override the config file, but have their own store
+	for profile, want := range map[string][3]string{
+		utils.DefaultProfile: {"/notes", "light", "priority"},
+		"work":               {filepath.Join(home, ".keep-work"), "dark", "priority"},
+		"personal":           {personal, "light", "priority"},
+	} {
+		c, err := configs.Load(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := [3]string{c.Get(configs.StorePath), c.Get(configs.Theme), c.Get(configs.Sort)}
+		if got != want {
+			t.Fatalf("%s: expected %q, got %q", profile, want, got)
+		}
+	}
+}
+
+func TestUseProfile(t *testing.T) {
+	setupConfig(t, "")
+	t.Setenv("KEEP_PROFILE", "")
+	t.Setenv("HOME", t.TempDir())
+
+	active := func() string {
+		t.Helper()
+		name, err := configs.ActiveProfile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+
+	if active() != utils.DefaultProfile {
+		t.Fatal("expected the default profile to be in use")
+	}
+	if err := configs.UseProfile("work"); err == nil {
+		t.Fatal("expected using an unknown profile to fail")
+	}
+	if err := configs.AddProfile("work", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := configs.UseProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if active() != "work" {
+		t.Fatalf("expected work to be in use, got %s", active())
+	}
+	t.Setenv("KEEP_PROFILE", "personal")
+	if active() != "personal" {
+		t.Fatal("expected KEEP_PROFILE to override the profile in use")
+	}
+	t.Setenv("KEEP_PROFILE", "")
+
+	if err := configs.RemoveProfile(utils.DefaultProfile); err == nil {
+		t.Fatal("expected removing the default profile to fail")
+	}
+	if err := configs.RemoveProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if active() != utils.DefaultProfile {
+		t.Fatal("expected the default profile to be in use once work is removed")
+	}
+	if err := configs.RemoveProfile("work"); err == nil {
+		t.Fatal("expected removing an unknown profile to fail")
+	}
+}
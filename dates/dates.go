@@ -0,0 +1,271 @@
+// Package dates parses the dates and durations typed on the command line, from
+// plain "2026-11-01" to "tomorrow 9am", "next friday" or "in 2 hours".
+package dates
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHour is the hour of the dates given without a time of day.
+const DefaultHour = 9
+
+var layouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// Parse returns the time described by s relative to now, in the location of
+// now. Besides absolute dates such as "2026-11-01" or "2026-11-01 15:04" it
+// understands:
+//
+//   - days: "today", "tomorrow", "yesterday", weekdays such as "friday" or
+//     "next fri", always the next one to come
+//   - times of day: "9am", "9:30pm", "21:00", "noon", "midnight", alone or
+//     after a day or an absolute date, as in "tomorrow 9am"
+//   - offsets: "in 2 hours", "in 3 days", "in 1 week", "in 30m"
+//
+// Days given without a time of day are taken at DefaultHour. A time of day
+// given alone is taken today, or tomorrow when it has already passed.
+func Parse(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			if layout == "2006-01-02" || layout == "2006/01/02" {
+				t = at(t, DefaultHour*time.Hour)
+			}
+			return t, nil
+		}
+	}
+
+	s = strings.ToLower(s)
+	if rest, ok := strings.CutPrefix(s, "in "); ok {
+		d, err := ParseDuration(rest)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q: %w", s, err)
+		}
+		return now.Add(d), nil
+	}
+
+	words := strings.Fields(s)
+	day, rest, ok := parseDay(words, now)
+	if !ok {
+		// an absolute date followed by a time of day
+		if t, err := time.ParseInLocation("2006-01-02", words[0], now.Location()); err == nil {
+			day, rest, ok = t, words[1:], true
+		}
+	}
+
+	if len(rest) == 0 {
+		if !ok {
+			return time.Time{}, fmt.Errorf("invalid date %q", s)
+		}
+		return at(day, DefaultHour*time.Hour), nil
+	}
+
+	clock, err := parseClock(strings.Join(rest, ""))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: %w", s, err)
+	}
+	if ok {
+		return at(day, clock), nil
+	}
+
+	t := at(now, clock)
+	if t.Before(now) {
+		t = at(now.AddDate(0, 0, 1), clock)
+	}
+	return t, nil
+}
+
+// parseDay parses the relative day words start with, returning the start of
+// that day and the words left.
+func parseDay(words []string, now time.Time) (time.Time, []string, bool) {
+	today := startOfDay(now)
+
+	switch words[0] {
+	case "today", "tonight":
+		if words[0] == "tonight" && len(words) == 1 {
+			return today, []string{"8pm"}, true
+		}
+		return today, words[1:], true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), words[1:], true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), words[1:], true
+	case "next":
+		if len(words) > 1 {
+			if wd, ok := weekdays[words[1]]; ok {
+				return nextWeekday(today, wd), words[2:], true
+			}
+			if words[1] == "week" {
+				return today.AddDate(0, 0, 7), words[2:], true
+			}
+		}
+	}
+	if wd, ok := weekdays[words[0]]; ok {
+		return nextWeekday(today, wd), words[1:], true
+	}
+	return time.Time{}, words, false
+}
+
+func nextWeekday(today time.Time, wd time.Weekday) time.Time {
+	days := (int(wd) - int(today.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+// parseClock parses a time of day into the time elapsed since midnight.
+func parseClock(s string) (time.Duration, error) {
+	switch s {
+	case "noon":
+		return 12 * time.Hour, nil
+	case "midnight":
+		return 0, nil
+	case "morning":
+		return DefaultHour * time.Hour, nil
+	case "evening":
+		return 18 * time.Hour, nil
+	}
+
+	s = strings.TrimPrefix(s, "at")
+	meridiem := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		s, meridiem = s[:len(s)-2], s[len(s)-2:]
+	}
+
+	hours, minutes, hasMinutes := strings.Cut(s, ":")
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	m := 0
+	if hasMinutes {
+		if m, err = strconv.Atoi(minutes); err != nil || m < 0 || m > 59 {
+			return 0, fmt.Errorf("invalid time of day %q", s)
+		}
+	}
+
+	switch meridiem {
+	case "":
+		if h < 0 || h > 23 {
+			return 0, fmt.Errorf("invalid time of day %q", s)
+		}
+	default:
+		if h < 1 || h > 12 {
+			return 0, fmt.Errorf("invalid time of day %q", s)
+		}
+		h %= 12
+		if meridiem == "pm" {
+			h += 12
+		}
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// ParseDuration parses a duration such as "1h", "90m" or "2d". Besides the
+// units of time.ParseDuration it accepts "d" for days and "w" for weeks, and
+// the long names of units, as in "2days".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		j := i
+		for j < len(s) && s[j] >= 'a' && s[j] <= 'z' {
+			j++
+		}
+		if i == 0 || j == i {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		value, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		unit, ok := units[s[i:j]]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q", s[i:j])
+		}
+		total += time.Duration(value * float64(unit))
+		s = s[j:]
+	}
+	return total, nil
+}
+
+var units = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// FormatDuration formats a duration the way ParseDuration reads it, e.g.
+// "1d2h" rather than "26h0m0s".
+func FormatDuration(d time.Duration) string {
+	if d == 0 {
+		return "0m"
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	for _, u := range []struct {
+		name string
+		d    time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}} {
+		if n := d / u.d; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, u.name)
+			d -= n * u.d
+		}
+	}
+	return b.String()
+}
+
+func startOfDay(t time.Time) time.Time {
+	return at(t, 0)
+}
+
+// at returns the given time of day on the day of t. It goes through the wall
+// clock rather than adding clock to midnight, which is off by an hour on the
+// days daylight saving time starts or ends.
+func at(t time.Time, clock time.Duration) time.Time {
+	y, m, d := t.Date()
+	h, min := int(clock/time.Hour), int(clock%time.Hour/time.Minute)
+	return time.Date(y, m, d, h, min, 0, 0, t.Location())
+}
+
+// SameDay reports whether a and b fall on the same day in the location of a.
+func SameDay(a, b time.Time) bool {
+	b = b.In(a.Location())
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,99 @@
+package dates_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/dates"
+)
+
+// a thursday
+var now = time.Date(2026, 10, 15, 14, 30, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]time.Time{
+		"2026-11-01":           time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC),
+		"2026-11-01 15:04":     time.Date(2026, 11, 1, 15, 4, 0, 0, time.UTC),
+		"2026-11-01 9pm":       time.Date(2026, 11, 1, 21, 0, 0, 0, time.UTC),
+		"today":                time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+		"tomorrow 9am":         time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		"Tomorrow at 9:30pm":   time.Date(2026, 10, 16, 21, 30, 0, 0, time.UTC),
+		"friday":               time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		"next thursday noon":   time.Date(2026, 10, 22, 12, 0, 0, 0, time.UTC),
+		"18:00":                time.Date(2026, 10, 15, 18, 0, 0, 0, time.UTC),
+		"8am":                  time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC),
+		"in 2 hours":           time.Date(2026, 10, 15, 16, 30, 0, 0, time.UTC),
+		"in 3 days":            time.Date(2026, 10, 18, 14, 30, 0, 0, time.UTC),
+		"2026-11-01T10:00:00Z": time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC),
+	} {
+		got, err := dates.Parse(s, now)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("%q: expected %v, got %v", s, want, got)
+		}
+	}
+
+	for _, s := range []string{"", "someday", "tomorrow 25:00", "13pm", "in two days"} {
+		if _, err := dates.Parse(s, now); err == nil {
+			t.Fatalf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestParseAcrossDaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// daylight saving time ends on 2026-11-01 and starts on 2027-03-14
+	now := time.Date(2026, 10, 31, 14, 30, 0, 0, ny)
+	for s, want := range map[string]time.Time{
+		"2026-11-01":       time.Date(2026, 11, 1, 9, 0, 0, 0, ny),
+		"2026-11-01 9pm":   time.Date(2026, 11, 1, 21, 0, 0, 0, ny),
+		"tomorrow":         time.Date(2026, 11, 1, 9, 0, 0, 0, ny),
+		"tomorrow evening": time.Date(2026, 11, 1, 18, 0, 0, 0, ny),
+		"2027-03-14 noon":  time.Date(2027, 3, 14, 12, 0, 0, 0, ny),
+	} {
+		got, err := dates.Parse(s, now)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("%q: expected %v, got %v", s, want, got)
+		}
+	}
+
+	got, err := dates.Parse("10am", time.Date(2026, 11, 1, 11, 0, 0, 0, ny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 11, 2, 10, 0, 0, 0, ny); !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"1h":      time.Hour,
+		"90m":     90 * time.Minute,
+		"2d":      48 * time.Hour,
+		"1w":      7 * 24 * time.Hour,
+		"1h30m":   90 * time.Minute,
+		"2 days":  48 * time.Hour,
+		"1.5h":    90 * time.Minute,
+		"3 hours": 3 * time.Hour,
+	} {
+		got, err := dates.ParseDuration(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %v, got %v", s, want, got)
+		}
+		if back, _ := dates.ParseDuration(dates.FormatDuration(got)); back != got {
+			t.Fatalf("%q doesn't round trip: %q", s, dates.FormatDuration(got))
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Package e2e_test runs the keep binary the way people and scripts do, with
+// a store of its own.
+package e2e_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// keep is the binary built for the tests.
+var keep string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "keep-e2e")
+	if err != nil {
+		panic(err)
+	}
+	keep = filepath.Join(dir, "keep")
+	build := exec.Command("go", "build", "-o", keep, "main.go")
+	build.Dir = ".."
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// env returns the environment of a store of its own for the test.
+func env(t *testing.T) []string {
+	t.Helper()
+	return append(os.Environ(),
+		"HOME="+t.TempDir(),
+		"XDG_CONFIG_HOME="+t.TempDir(),
+		"KEEP_CONFIG=",
+		"KEEP_PROFILE=",
+		"KEEP_PASSPHRASE=",
+		"KEEP_KEYRING=",
+		"NO_COLOR=",
+		"CLICOLOR_FORCE=",
+	)
+}
+
+// storeOf returns the store of the given environment.
+func storeOf(env []string) string {
+	var home string
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "HOME="); ok {
+			home = v
+		}
+	}
+	return filepath.Join(home, ".keep")
+}
+
+// run runs keep with args and returns its output and exit code.
+func run(t *testing.T, env []string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	c := exec.Command(keep, args...)
+	c.Env = env
+	c.Stdout, c.Stderr = &out, &errOut
+	err := c.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		code = exit.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// must runs keep with args, failing the test unless it succeeds.
+func must(t *testing.T, env []string, args ...string) string {
+	t.Helper()
+	out, errOut, code := run(t, env, args...)
+	if code != 0 {
+		t.Fatalf("keep %s exited with %v: %s", strings.Join(args, " "), code, errOut)
+	}
+	return out
+}
+
+func TestColorFlags(t *testing.T) {
+	env := env(t)
+	must(t, env, "group", "books", "to read")
+
+	// the color mode and the color of the note, wherever they are
+	must(t, env, "--color", "never", "books", "Dune", "--note-color", "green")
+	must(t, env, "books", "Solaris", "--color", "always", "--note-color", "red")
+
+	out := must(t, env, "read", "books", "--color", "always")
+	for _, want := range []string{"\x1b[32;1m[ ] Dune", "\x1b[31;1m[ ] Solaris"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+	if out := must(t, env, "--color", "never", "read", "books"); strings.Contains(out, "\x1b[") {
+		t.Fatalf("expected no colors in %q", out)
+	}
+	if out := must(t, env, "read", "books", "--no-color"); strings.Contains(out, "\x1b[") {
+		t.Fatalf("expected no colors in %q", out)
+	}
+	if out := must(t, append(env, "CLICOLOR_FORCE=1"), "--no-color", "read", "books"); strings.Contains(out, "\x1b[") {
+		t.Fatalf("expected --no-color to win over CLICOLOR_FORCE, got %q", out)
+	}
+	if _, _, code := run(t, env, "read", "books", "--no-color", "--color", "always"); code != 2 {
+		t.Fatalf("expected --no-color along with --color to exit with 2, got %v", code)
+	}
+
+	if _, _, code := run(t, env, "books", "Dune", "--note-color", "never"); code != 2 {
+		t.Fatalf("expected an unknown note color to exit with 2, got %v", code)
+	}
+}
+
+func TestReadOnlyCommandsLeaveStore(t *testing.T) {
+	env := env(t)
+	store := storeOf(env)
+
+	for _, args := range [][]string{
+		{"--help"},
+		{"help", "read"},
+		{"config", "get", "default_group"},
+		{"completion", "bash"},
+		{"__complete", "read", ""},
+	} {
+		must(t, env, args...)
+		if _, err := os.Stat(store); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("keep %s created the store: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	must(t, env, "group", "books", "to read")
+	must(t, env, "read", "books")
+	if _, err := os.Stat(filepath.Join(store, ".snapshots")); err != nil {
+		t.Fatalf("expected the daily snapshot to be taken: %v", err)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	env := env(t)
+
+	// a fresh install has no notes rather than no store or default group
+	out, errOut, code := run(t, env, "all")
+	if code != 0 || errOut != "" || !strings.Contains(out, "0 notes") {
+		t.Fatalf("unexpected output %q %q %v", out, errOut, code)
+	}
+	if _, errOut, code := run(t, env, "list"); code != 0 || errOut != "" {
+		t.Fatalf("keep list exited with %v: %s", code, errOut)
+	}
+
+	must(t, env, "group", "books", "to read")
+	must(t, env, "books", "Dune")
+	for _, c := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"read", "books", "1"}, 0},
+		{[]string{"read", "books", "abc"}, 2},
+		{[]string{"read", "books", "7"}, 3},
+		{[]string{"read", "movies"}, 3},
+		{[]string{"delete", "books", "abc"}, 2},
+	} {
+		if _, _, code := run(t, env, c.args...); code != c.want {
+			t.Fatalf("keep %s: expected exit code %v, got %v", strings.Join(c.args, " "), c.want, code)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	env := env(t)
+	must(t, env, "profile", "add", "work")
+
+	out, errOut, code := run(t, env, "--profile", "work", "all")
+	if code != 0 || errOut != "" || !strings.Contains(out, "0 notes") {
+		t.Fatalf("unexpected output of a new profile %q %q %v", out, errOut, code)
+	}
+	must(t, env, "--profile", "work", "Standup at 10")
+	if out := must(t, env, "--profile", "work", "all"); !strings.Contains(out, "Standup at 10") {
+		t.Fatalf("note not added to the profile: %q", out)
+	}
+	if out := must(t, env, "all"); strings.Contains(out, "Standup at 10") {
+		t.Fatalf("note of the profile added to the default one: %q", out)
+	}
+}
+
+func TestLockedGroups(t *testing.T) {
+	env := env(t)
+	unlocked := append(env[:len(env):len(env)], "KEEP_PASSPHRASE=correct horse")
+	must(t, unlocked, "group", "diary", "private", "--encrypt")
+	must(t, unlocked, "diary", "Dear diary #work")
+	must(t, env, "group", "books", "to read")
+	must(t, env, "books", "Dune #work")
+	must(t, env, "group", "smart", "work", "tag:work")
+
+	// without the passphrase, the encrypted group is skipped with a warning
+	for _, args := range [][]string{
+		{"search", "work"},
+		{"read", "work"},
+		{"timeline", "--list"},
+	} {
+		out, errOut, code := run(t, env, args...)
+		if code != 0 {
+			t.Fatalf("keep %s exited with %v: %s", strings.Join(args, " "), code, errOut)
+		}
+		if !strings.Contains(out, "Dune") || strings.Contains(out, "Dear diary") {
+			t.Fatalf("keep %s: unexpected output %q", strings.Join(args, " "), out)
+		}
+		if !strings.Contains(errOut, "warning: skipped the encrypted groups diary") {
+			t.Fatalf("keep %s: expected a warning, got %q", strings.Join(args, " "), errOut)
+		}
+	}
+
+	// stats count what is stored in the clear
+	out, errOut, code := run(t, env, "stats")
+	if code != 0 || !strings.Contains(out, "2 live") || !strings.Contains(errOut, "warning: skipped the encrypted groups diary") {
+		t.Fatalf("keep stats exited with %v: %q %q", code, out, errOut)
+	}
+}
+
+func TestAgendaLeavesOutDoneNotes(t *testing.T) {
+	env := env(t)
+	must(t, env, "group", "books", "to read")
+	must(t, env, "books", "Return Dune", "--due", "2026-01-01")
+	must(t, env, "books", "Renew the card", "--due", "2099-01-01", "--remind-before", "876000h")
+	must(t, env, "done", "books", "1")
+	must(t, env, "done", "books", "2")
+
+	if out := must(t, env, "due"); strings.TrimSpace(out) != "nothing is due" {
+		t.Fatalf("expected nothing due, got %q", out)
+	}
+	if out := must(t, env, "due", "--reminders"); out != "" {
+		t.Fatalf("expected no reminders, got %q", out)
+	}
+}
+
+func TestDefaultGroupSetting(t *testing.T) {
+	env := env(t)
+	for _, group := range []string{"info", "books!", "../inbox"} {
+		if _, _, code := run(t, env, "config", "set", "default_group", group); code == 0 {
+			t.Fatalf("expected default group %q to be refused", group)
+		}
+	}
+
+	// the default group is created along with its first note
+	must(t, env, "config", "set", "default_group", "inbox")
+	must(t, env, "Buy milk")
+	if out := must(t, env, "read", "inbox"); !strings.Contains(out, "Buy milk") {
+		t.Fatalf("expected the note in inbox, got %q", out)
+	}
+}
+
+// completions runs the shell completion of keep for args and returns the
+// completions it offers, without their directive.
+func completions(t *testing.T, env []string, args ...string) []string {
+	t.Helper()
+	out := must(t, env, append([]string{"__complete"}, args...)...)
+	var result []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if !strings.HasPrefix(line, ":") {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func TestCompletion(t *testing.T) {
+	env := env(t)
+
+	// nothing to complete, nor a store to create, before the first group
+	if got := completions(t, env, "read", ""); len(got) != 0 {
+		t.Fatalf("expected no completions, got %q", got)
+	}
+	if _, err := os.Stat(storeOf(env)); !os.IsNotExist(err) {
+		t.Fatalf("expected completion to leave the store alone, got %v", err)
+	}
+
+	must(t, env, "group", "work", "work stuff")
+	must(t, env, "group", "work/projectA", "the project")
+	must(t, env, "work", "Fix the build #work")
+	must(t, env, "work/projectA", "Review the design #work")
+	must(t, env, "group", "smart", "urgent", "tag:work")
+	unlocked := append(env[:len(env):len(env)], "KEEP_PASSPHRASE=correct horse")
+	must(t, unlocked, "group", "diary", "private", "--encrypt")
+	must(t, unlocked, "diary", "Dear diary")
+
+	for _, c := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"read", ""}, []string{"diary\tprivate", "urgent\ttag:work", "work\twork stuff", "work/projectA\tthe project"}},
+		{[]string{"done", ""}, []string{"diary\tprivate", "urgent\ttag:work", "work\twork stuff", "work/projectA\tthe project"}},
+		{[]string{"done", "work", ""}, []string{"1\tFix the build #work"}},
+		{[]string{"done", "work/projectA", ""}, []string{"1\tReview the design #work"}},
+		{[]string{"done", "urgent", ""}, nil},
+		{[]string{"done", "diary", ""}, []string{"1\tencrypted"}},
+		{[]string{"done", "missing", ""}, nil},
+		{[]string{"done", "work", "1", ""}, nil},
+	} {
+		got := completions(t, env, c.args...)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Fatalf("keep %s: expected %q, got %q", strings.Join(c.args, " "), c.want, got)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Package gitsync keeps the notes of many machines in sync through a git
+// repository.
+//
+// The repository lives in the .sync folder of the keep folder. Each group is
+// exported into it as a text file (see text.go) so git can diff and merge
+// them. When both sides changed the same group, the group is merged note by
+// note instead of leaving conflict markers behind (see merge3).
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+const (
+	dirName    = ".sync"
+	groupsDir  = "groups"
+	extension  = ".txt"
+	remoteName = "origin"
+	branch     = "main"
+)
+
+var (
+	ErrNotInitialized = errors.New("sync is not set up, run keep sync init <remote> first")
+	ErrRemoteAhead    = errors.New("remote has changes not pulled yet, run keep sync pull first")
+	ErrKeyConflict    = errors.New("group encrypted with different keys on both sides")
+)
+
+// Dir returns the folder of the sync repository.
+func Dir() (string, error) {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(kfp, dirName), nil
+}
+
+// Init sets up the sync repository with the given remote and pulls from it.
+// Running it again just changes the remote.
+func Init(remote string) error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	if isRepo(dir) {
+		if _, err := git(dir, "remote", "set-url", remoteName, remote); err != nil {
+			return err
+		}
+		_, err := Pull()
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("unable to create sync dir: %w", err)
+	}
+	if _, err := git(dir, "init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err := git(dir, "symbolic-ref", "HEAD", "refs/heads/"+branch); err != nil {
+		return err
+	}
+	// commits need an identity, fall back to one if the user has none
+	if name, _ := git(dir, "config", "user.name"); strings.TrimSpace(name) == "" {
+		if _, err := git(dir, "config", "user.name", "keep"); err != nil {
+			return err
+		}
+	}
+	if email, _ := git(dir, "config", "user.email"); strings.TrimSpace(email) == "" {
+		if _, err := git(dir, "config", "user.email", "keep@"+hostname()); err != nil {
+			return err
+		}
+	}
+	if _, err := git(dir, "remote", "add", remoteName, remote); err != nil {
+		return err
+	}
+
+	_, err = Pull()
+	return err
+}
+
+// Push sends the local notes to the remote. It fails with ErrRemoteAhead if
+// the remote has changes that must be pulled first.
+func Push() error {
+	dir, err := repoDir()
+	if err != nil {
+		return err
+	}
+	if err := commitStore(dir); err != nil {
+		return err
+	}
+	if !hasCommits(dir) {
+		return nil
+	}
+	if _, err := git(dir, "push", "--quiet", remoteName, "HEAD:refs/heads/"+branch); err != nil {
+		if strings.Contains(err.Error(), "rejected") {
+			return ErrRemoteAhead
+		}
+		return err
+	}
+	return nil
+}
+
+// Pull merges the notes of the remote into the local ones. It returns the
+// groups changed on both sides, which were merged note by note.
+func Pull() ([]string, error) {
+	dir, err := repoDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := commitStore(dir); err != nil {
+		return nil, err
+	}
+
+	if _, err := git(dir, "fetch", "--quiet", remoteName); err != nil {
+		return nil, err
+	}
+
+	remoteRef := "refs/remotes/" + remoteName + "/" + branch
+	if _, err := git(dir, "rev-parse", "--verify", "--quiet", remoteRef); err != nil {
+		return nil, nil // nothing pushed to the remote yet
+	}
+
+	var merged []string
+	if !hasCommits(dir) {
+		if _, err := git(dir, "reset", "--quiet", "--hard", remoteRef); err != nil {
+			return nil, err
+		}
+	} else if _, err := git(dir, "merge", "--quiet", "--no-edit", "--allow-unrelated-histories", remoteRef); err != nil {
+		merged, err = resolveConflicts(dir)
+		if err != nil {
+			git(dir, "merge", "--abort")
+			return nil, err
+		}
+	}
+
+	return merged, importStore(dir)
+}
+
+// resolveConflicts merges note by note every group git couldn't merge and
+// commits the merge.
+func resolveConflicts(dir string) ([]string, error) {
+	out, err := git(dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	conflicted := strings.Fields(out)
+	if len(conflicted) == 0 {
+		return nil, fmt.Errorf("unable to merge remote changes")
+	}
+
+	var groups []string
+	for _, file := range conflicted {
+		var stages [3]*group
+		for i := range stages {
+			content, err := git(dir, "show", fmt.Sprintf(":%d:%s", i+1, file))
+			if err != nil {
+				continue // the group doesn't exist on that side
+			}
+			g, err := decode([]byte(content))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %w", file, err)
+			}
+			stages[i] = &g
+		}
+
+		result, err := merge3(groupOf(file), stages[0], stages[1], stages[2])
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			if _, err := git(dir, "rm", "--quiet", file); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := os.WriteFile(filepath.Join(dir, file), encode(*result), 0600); err != nil {
+				return nil, err
+			}
+			if _, err := git(dir, "add", file); err != nil {
+				return nil, err
+			}
+		}
+		groups = append(groups, groupOf(file))
+	}
+
+	if _, err := git(dir, "commit", "--quiet", "--no-edit"); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// commitStore exports all groups into the repository and commits them.
+func commitStore(dir string) error {
+	names, err := notes.GroupNames()
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(dir, groupsDir)
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return err
+	}
+
+	exported := map[string]bool{}
+	for _, name := range names {
+		header, groupNotes, err := notes.ReadGroup(name)
+		if err != nil {
+			return fmt.Errorf("unable to export group %s: %w", name, err)
+		}
+		content := encode(group{Header: header, Notes: groupNotes})
+		file := filepath.Join(target, filepath.FromSlash(name)+extension)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("unable to export group %s: %w", name, err)
+		}
+		if err := os.WriteFile(file, content, 0600); err != nil {
+			return fmt.Errorf("unable to export group %s: %w", name, err)
+		}
+		exported[file] = true
+	}
+
+	// groups deleted since the last sync
+	err = filepath.WalkDir(target, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || exported[p] {
+			return err
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := git(dir, "add", "--all", groupsDir); err != nil {
+		return err
+	}
+	status, err := git(dir, "status", "--porcelain")
+	if err != nil || strings.TrimSpace(status) == "" {
+		return err
+	}
+	_, err = git(dir, "commit", "--quiet", "-m", "keep sync from "+hostname())
+	return err
+}
+
+// importStore makes the local groups match the ones in the repository.
+func importStore(dir string) error {
+	source := filepath.Join(dir, groupsDir)
+
+	inRepo := map[string]bool{}
+	err := filepath.WalkDir(source, func(p string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, extension) {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		g, err := decode(content)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", p, err)
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), extension)
+		inRepo[name] = true
+		return notes.WriteGroup(name, g.Header, g.Notes)
+	})
+	if err != nil {
+		return err
+	}
+
+	names, err := notes.GroupNames()
+	if err != nil {
+		return err
+	}
+	// subgroups first, and groups whose subgroups are still in the repository
+	// are kept
+	for i := len(names) - 1; i >= 0; i-- {
+		if inRepo[names[i]] {
+			continue
+		}
+		if err := notes.DeleteGroup(names[i]); err != nil && !errors.Is(err, notes.ErrHasSubgroups) {
+			return err
+		}
+	}
+	return nil
+}
+
+func repoDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	if !isRepo(dir) {
+		return "", ErrNotInitialized
+	}
+	return dir, nil
+}
+
+func isRepo(dir string) bool {
+	return utils.DoesFileExists(filepath.Join(dir, ".git"))
+}
+
+func hasCommits(dir string) bool {
+	_, err := git(dir, "rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+func groupOf(file string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(file, groupsDir+"/"), extension)
+}
+
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return "localhost"
+	}
+	return host
+}
+
+func git(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,151 @@
+package gitsync_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/gitsync"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// machine switches the store to the one of a machine, i.e. its own home
+func machine(t *testing.T, home string) {
+	t.Helper()
+	t.Setenv("HOME", home)
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func bareRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	remote := filepath.Join(t.TempDir(), "notes.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	return remote
+}
+
+func texts(t *testing.T, group string) []string {
+	t.Helper()
+	ch, err := notes.ReadAllNotes(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for n := range ch {
+		result = append(result, n.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestSyncBetweenMachines(t *testing.T) {
+	remote := bareRemote(t)
+	laptop, server := t.TempDir(), t.TempDir()
+
+	machine(t, laptop)
+	if _, err := notes.NewNoteFile("books", "books to read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("books", "Programming Language Pragmatics"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitsync.Init(remote); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitsync.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	machine(t, server)
+	if err := gitsync.Init(remote); err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(t, "books"); len(got) != 1 || got[0] != "Programming Language Pragmatics" {
+		t.Fatalf("unexpected notes on server %q", got)
+	}
+
+	// both machines add a note to the same group, taking the same id
+	if err := notes.AddNote("books", "Crafting Interpreters"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitsync.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	machine(t, laptop)
+	if err := notes.AddNote("books", "The Go Programming Language"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitsync.Push(); err != gitsync.ErrRemoteAhead {
+		t.Fatalf("expected ErrRemoteAhead, got %v", err)
+	}
+	merged, err := gitsync.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 || merged[0] != "books" {
+		t.Fatalf("expected books to be merged note by note, got %v", merged)
+	}
+	want := []string{"Crafting Interpreters", "Programming Language Pragmatics", "The Go Programming Language"}
+	if got := texts(t, "books"); !equal(got, want) {
+		t.Fatalf("unexpected notes after merge %q", got)
+	}
+	if err := gitsync.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	machine(t, server)
+	if _, err := gitsync.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(t, "books"); !equal(got, want) {
+		t.Fatalf("unexpected notes on server after pull %q", got)
+	}
+
+	// deleting a group on one machine deletes it everywhere
+	if err := notes.DeleteGroup("books"); err != nil {
+		t.Fatal(err)
+	}
+	if err := gitsync.Push(); err != nil {
+		t.Fatal(err)
+	}
+	machine(t, laptop)
+	if _, err := gitsync.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	if names, _ := notes.GroupNames(); len(names) != 0 {
+		t.Fatalf("expected no groups, got %v", names)
+	}
+}
+
+func TestNotInitialized(t *testing.T) {
+	machine(t, t.TempDir())
+	if err := gitsync.Push(); err != gitsync.ErrNotInitialized {
+		t.Fatalf("expected ErrNotInitialized, got %v", err)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package gitsync
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+// merge3 merges two revisions of a group note by note, given the revision
+// both came from. A nil revision means the group doesn't exist on that side.
+//
+// Notes are matched by UID, so notes added on both sides are all kept even
+// when they took the same id. A note changed on one side only takes that
+// change. A note deleted on one side and changed on the other is kept changed.
+// When both sides changed a note in different ways, both revisions are kept:
+// ours keeps the note UID and theirs becomes a new note, encrypted again in
+// bound groups (see notes.FlagBound).
+//
+// Revisions whose notes are encrypted with different keys can't be merged
+// note by note and fail with ErrKeyConflict.
+//
+// Ours keeps its ids; notes of theirs whose id is taken get a new one.
+func merge3(name string, base, ours, theirs *group) (*group, error) {
+	switch {
+	case ours == nil && theirs == nil:
+		return nil, nil
+	case ours == nil:
+		if base != nil && sameGroup(base, theirs) {
+			return nil, nil // deleted by us, untouched by them
+		}
+		return theirs, nil
+	case theirs == nil:
+		if base != nil && sameGroup(base, ours) {
+			return nil, nil
+		}
+		return ours, nil
+	}
+	if !sameKey(ours.Header, theirs.Header) {
+		return nil, fmt.Errorf("%w: %s, rename it on one side and sync again", ErrKeyConflict, name)
+	}
+	if base == nil {
+		base = &group{}
+	}
+
+	merged := &group{Header: mergeHeader(base.Header, ours.Header, theirs.Header)}
+
+	baseNotes, ourNotes, theirNotes := byKey(base), byKey(ours), byKey(theirs)
+
+	var kept, theirsKept []notes.Note
+	keep := func(n notes.Note, fromTheirs bool) {
+		if fromTheirs {
+			theirsKept = append(theirsKept, n)
+		} else {
+			kept = append(kept, n)
+		}
+	}
+
+	for _, k := range keys(baseNotes, ourNotes, theirNotes) {
+		b, inBase := baseNotes[k]
+		o, inOurs := ourNotes[k]
+		t, inTheirs := theirNotes[k]
+
+		switch {
+		case inOurs && inTheirs:
+			switch {
+			case o == t, inBase && t == b:
+				keep(o, false)
+			case inBase && o == b:
+				keep(t, true)
+			default:
+				keep(o, false)
+				if err := notes.Rebind(name, theirs.Header, &t, notes.NewUID(t.CreatedAt)); err != nil {
+					return nil, fmt.Errorf("unable to keep both revisions of note %v of group %s: %w", t.Id, name, err)
+				}
+				keep(t, true)
+			}
+		case inOurs:
+			// deleted by them - kept only if we changed it
+			if !inBase || o != b {
+				keep(o, false)
+			}
+		case inTheirs:
+			if !inBase || t != b {
+				keep(t, true)
+			}
+		}
+	}
+
+	taken := map[int64]bool{}
+	for _, n := range kept {
+		taken[n.Id] = true
+	}
+	for _, n := range theirsKept {
+		if taken[n.Id] {
+			merged.Header.SizeAlltime++
+			n.Id = int64(merged.Header.SizeAlltime)
+		}
+		taken[n.Id] = true
+		kept = append(kept, n)
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Id < kept[j].Id })
+	merged.Notes = kept
+	return merged, nil
+}
+
+// sameKey reports whether the notes of both groups are stored the same way,
+// that is both plain or both encrypted with the same key. Encrypted groups
+// created apart under the same name have different salts, so different keys,
+// even for the same passphrase.
+func sameKey(a, b notes.NoteFileHeader) bool {
+	const flags = notes.FlagEncrypted | notes.FlagBound
+	return a.Flags&flags == b.Flags&flags && a.Salt == b.Salt
+}
+
+func mergeHeader(base, ours, theirs notes.NoteFileHeader) notes.NoteFileHeader {
+	merged := ours
+	if ours.Title == base.Title {
+		merged.Title = theirs.Title
+	}
+	if ours.Description == base.Description {
+		merged.Description = theirs.Description
+	}
+	merged.SizeAlltime = max(ours.SizeAlltime, theirs.SizeAlltime)
+	return merged
+}
+
+// noteKey identifies a note across revisions: by UID, or by id for notes
+// exported before they had UIDs.
+type noteKey struct {
+	uid notes.UID
+	id  int64
+}
+
+func byKey(g *group) map[noteKey]notes.Note {
+	m := make(map[noteKey]notes.Note, len(g.Notes))
+	for _, n := range g.Notes {
+		if n.UID.IsZero() {
+			m[noteKey{id: n.Id}] = n
+		} else {
+			m[noteKey{uid: n.UID}] = n
+		}
+	}
+	return m
+}
+
+// keys returns the keys of all maps, ordered by the id of their notes.
+func keys(maps ...map[noteKey]notes.Note) []noteKey {
+	ids := map[noteKey]int64{}
+	for _, m := range maps {
+		for k, n := range m {
+			ids[k] = n.Id
+		}
+	}
+	result := make([]noteKey, 0, len(ids))
+	for k := range ids {
+		result = append(result, k)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if ids[result[i]] != ids[result[j]] {
+			return ids[result[i]] < ids[result[j]]
+		}
+		return result[i].uid.String() < result[j].uid.String()
+	})
+	return result
+}
+
+func sameGroup(a, b *group) bool {
+	if a.Header != b.Header || len(a.Notes) != len(b.Notes) {
+		return false
+	}
+	for i := range a.Notes {
+		if a.Notes[i] != b.Notes[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package gitsync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+func note(id int64, text string) notes.Note {
+	return notes.NewNote(id, text, 36, 1700000000000+id)
+}
+
+func edit(n notes.Note, text string) notes.Note {
+	n.Text = [300]rune{}
+	copy(n.Text[:], []rune(text))
+	return n
+}
+
+func books(sizeAlltime uint32, ns ...notes.Note) *group {
+	g := &group{
+		Header: notes.NewNoteFileHeader("books", "books to read", 0, sizeAlltime),
+		Notes:  ns,
+	}
+	g.Header.CreatedAt = 0
+	return g
+}
+
+func mustMerge(t *testing.T, base, ours, theirs *group) *group {
+	t.Helper()
+	got, err := merge3("books", base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestMerge3(t *testing.T) {
+	a, b := note(1, "a"), note(2, "b")
+	base := books(2, a, b)
+
+	t.Run("Changed on one side", func(t *testing.T) {
+		got := mustMerge(t, base, books(2, a, b), books(2, edit(a, "A"), b))
+		if len(got.Notes) != 2 || got.Notes[0].String() != "A" {
+			t.Fatalf("unexpected merge %+v", got.Notes)
+		}
+	})
+
+	t.Run("Changed on both sides", func(t *testing.T) {
+		got := mustMerge(t, base, books(2, edit(a, "x"), b), books(2, edit(a, "y"), b))
+		if len(got.Notes) != 3 || got.Notes[0].String() != "x" || got.Notes[2].String() != "y" || got.Notes[2].Id != 3 {
+			t.Fatalf("unexpected merge %+v", got.Notes)
+		}
+		if got.Notes[0].UID != a.UID || got.Notes[2].UID == a.UID {
+			t.Fatal("both revisions kept the same uid")
+		}
+		if got.Header.SizeAlltime != 3 {
+			t.Fatalf("expected size alltime 3, got %v", got.Header.SizeAlltime)
+		}
+	})
+
+	t.Run("Added on both sides with the same id", func(t *testing.T) {
+		got := mustMerge(t, base, books(3, a, b, note(3, "ours")), books(3, a, b, note(3, "theirs")))
+		if len(got.Notes) != 4 || got.Notes[2].String() != "ours" || got.Notes[3].String() != "theirs" || got.Notes[3].Id != 4 {
+			t.Fatalf("unexpected merge %+v", got.Notes)
+		}
+	})
+
+	t.Run("Deleted on one side, changed on the other", func(t *testing.T) {
+		got := mustMerge(t, base, books(2, b), books(2, edit(a, "A"), b))
+		if len(got.Notes) != 2 || got.Notes[0].String() != "A" {
+			t.Fatalf("unexpected merge %+v", got.Notes)
+		}
+	})
+
+	t.Run("Deleted on one side only", func(t *testing.T) {
+		got := mustMerge(t, base, books(2, b), books(2, a, b))
+		if len(got.Notes) != 1 || got.Notes[0].Id != 2 {
+			t.Fatalf("unexpected merge %+v", got.Notes)
+		}
+	})
+
+	t.Run("Encrypted with different keys", func(t *testing.T) {
+		ours, theirs := books(2, a, b), books(2, edit(a, "A"), b)
+		ours.Header.Flags = notes.FlagEncrypted | notes.FlagBound
+		theirs.Header.Flags = ours.Header.Flags
+		ours.Header.Salt[0], theirs.Header.Salt[0] = 1, 2
+		if _, err := merge3("books", base, ours, theirs); !errors.Is(err, ErrKeyConflict) {
+			t.Fatalf("expected ErrKeyConflict, got %v", err)
+		}
+		theirs.Header.Salt = ours.Header.Salt
+		if _, err := merge3("books", base, ours, theirs); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Group deleted and untouched", func(t *testing.T) {
+		if got := mustMerge(t, base, nil, books(2, a, b)); got != nil {
+			t.Fatalf("expected group to stay deleted, got %+v", got)
+		}
+	})
+}
+
+func TestEncodeDecode(t *testing.T) {
+	due := note(3, "ünïcödé")
+	due.Due, due.RemindBefore = 1793523600000, 3600000
+	copy(due.Repeat[:], "0 9 * * MON")
+	due.DoneAt = 1793527200000
+	due.Priority = notes.PriorityUrgent
+	due.Pinned = true
+	if err := due.SetOrigin("work/projectAlpha/in#box", 12); err != nil {
+		t.Fatal(err)
+	}
+	g := *books(3, note(1, "with \"quotes\"\nand a new line"), due)
+	got, err := decode(encode(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header != g.Header || len(got.Notes) != 2 || got.Notes[0] != g.Notes[0] || got.Notes[1] != g.Notes[1] {
+		t.Fatalf("round trip mismatch:\n%+v\n%+v", g, got)
+	}
+
+	// lines written before notes had uids
+	old := "# keep group\ntitle: \"books\"\ndescription: \"\"\ncreated: 2026-10-17T09:30:00.000Z\nsize-alltime: 1\n---\n1 2026-10-17T09:30:00.000Z 36 \"a b\"\n"
+	got, err = decode([]byte(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Notes) != 1 || got.Notes[0].String() != "a b" || !got.Notes[0].UID.IsZero() {
+		t.Fatalf("unexpected notes %+v", got.Notes)
+	}
+}
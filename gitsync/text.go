@@ -0,0 +1,406 @@
+package gitsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/dates"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/recur"
+)
+
+// Groups are stored in the repository as text, one note per line, so git can
+// diff and merge them:
+//
+//	# keep group
+//	title: "books"
+//	description: "books to read"
+//	created: 2026-10-17T09:30:00.000Z
+//	size-alltime: 3
+//	---
+//	1 019a3b1c-2d40-7e5f-8a6b-7c8d9e0f1a2b 2026-10-17T09:30:00.000Z 36 "Programming Language Pragmatics"
+//	3 019a3b1d-1780-7c2d-9e4f-5a6b7c8d9e0f 2026-10-17T09:31:00.000Z 31 "Crafting Interpreters"
+//
+// Each note line holds its id, UID, creation time, color and text. Lines
+// written before notes had UIDs lack the UID. Between color and text come the
+// attributes only some notes have, as key=value pairs:
+//
+//	4 019a3b1e-0a10-7b3c-8d4e-6f7a8b9c0d1e 2026-10-17T09:32:00.000Z 32 due=2026-11-01T09:00:00.000Z remind-before=1h pinned=true repeat="0 9 1 * *" "Return the books"
+//
+// Notes moved or copied from another group may record it, as in
+// moved-from="inbox#12".
+//
+// Encrypted groups also carry flags, salt and key-check lines, and their notes
+// hold "enc:<nonce>:<tag>:<ciphertext>" in hex instead of a quoted text, so
+// nothing is ever decrypted to be synced.
+
+const (
+	textMagic   = "# keep group"
+	separator   = "---"
+	stampLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+type group struct {
+	Header notes.NoteFileHeader
+	Notes  []notes.Note // live notes sorted by id
+}
+
+func encode(g group) []byte {
+	var b bytes.Buffer
+	h := g.Header
+
+	fmt.Fprintln(&b, textMagic)
+	fmt.Fprintf(&b, "title: %s\n", strconv.Quote(runesString(h.Title[:])))
+	fmt.Fprintf(&b, "description: %s\n", strconv.Quote(runesString(h.Description[:])))
+	fmt.Fprintf(&b, "created: %s\n", formatStamp(h.CreatedAt))
+	fmt.Fprintf(&b, "size-alltime: %d\n", h.SizeAlltime)
+	if h.Flags != 0 {
+		fmt.Fprintf(&b, "flags: %d\n", h.Flags)
+		fmt.Fprintf(&b, "salt: %s\n", hex.EncodeToString(h.Salt[:]))
+		fmt.Fprintf(&b, "key-check: %s\n", hex.EncodeToString(h.KeyCheck[:]))
+	}
+	fmt.Fprintln(&b, separator)
+
+	for _, n := range g.Notes {
+		b.WriteString(encodeNote(h, n))
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes()
+}
+
+func encodeNote(h notes.NoteFileHeader, n notes.Note) string {
+	var text string
+	if h.Encrypted() {
+		text = "enc:" + hex.EncodeToString(n.Nonce[:]) + ":" + hex.EncodeToString(n.Tag[:]) + ":" + hex.EncodeToString(runesBytes(n.Text[:]))
+	} else {
+		text = strconv.Quote(n.String())
+	}
+	fields := []string{fmt.Sprint(n.Id), n.UID.String(), formatStamp(n.CreatedAt), fmt.Sprint(n.Color)}
+	fields = append(fields, encodeAttrs(n)...)
+	return strings.Join(append(fields, text), " ")
+}
+
+// encodeAttrs returns the attributes of a note that aren't left unset.
+func encodeAttrs(n notes.Note) []string {
+	var attrs []string
+	if n.Due != 0 {
+		attrs = append(attrs, "due="+formatStamp(n.Due))
+	}
+	if n.RemindBefore != 0 {
+		attrs = append(attrs, "remind-before="+dates.FormatDuration(time.Duration(n.RemindBefore)*time.Millisecond))
+	}
+	if n.Done() {
+		attrs = append(attrs, "done="+formatStamp(n.DoneAt))
+	}
+	if n.Pinned {
+		attrs = append(attrs, "pinned=true")
+	}
+	if n.Priority != notes.PriorityNormal {
+		attrs = append(attrs, "priority="+n.Priority.String())
+	}
+	if group, id := n.Origin(); group != "" {
+		attrs = append(attrs, "moved-from="+strconv.Quote(fmt.Sprintf("%s#%d", group, id)))
+	}
+	if n.Repeats() {
+		attrs = append(attrs, "repeat="+strconv.Quote(strings.TrimRight(string(n.Repeat[:]), "\x00")))
+	}
+	return attrs
+}
+
+// cutAttr cuts the key=value attribute s starts with. Values holding spaces
+// are quoted.
+func cutAttr(s string) (key, value, rest string, err error) {
+	key, after, ok := strings.Cut(s, "=")
+	if !ok || strings.Contains(key, " ") {
+		return "", "", "", fmt.Errorf("invalid note")
+	}
+	if strings.HasPrefix(after, "\"") {
+		quoted, err := strconv.QuotedPrefix(after)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid %s: %w", key, err)
+		}
+		value, _ = strconv.Unquote(quoted)
+		after = after[len(quoted):]
+	} else {
+		value, after, _ = strings.Cut(after, " ")
+		after = " " + after
+	}
+	rest, ok = strings.CutPrefix(after, " ")
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid note")
+	}
+	return key, value, rest, nil
+}
+
+func decodeAttr(n *notes.Note, key, value string) error {
+	switch key {
+	case "due":
+		due, err := parseStamp(value)
+		if err != nil {
+			return err
+		}
+		n.Due = due
+	case "remind-before":
+		d, err := dates.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid remind-before: %w", err)
+		}
+		n.RemindBefore = d.Milliseconds()
+	case "done":
+		done, err := parseStamp(value)
+		if err != nil {
+			return err
+		}
+		n.DoneAt = done
+	case "pinned":
+		pinned, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid pinned %q", value)
+		}
+		n.Pinned = pinned
+	case "priority":
+		p, err := notes.ParsePriority(value)
+		if err != nil {
+			return err
+		}
+		n.Priority = p
+	case "moved-from":
+		i := strings.LastIndex(value, "#")
+		id, err := strconv.ParseInt(value[i+1:], 10, 64)
+		if i <= 0 || err != nil {
+			return fmt.Errorf("invalid moved-from %q", value)
+		}
+		if err := n.SetOrigin(value[:i], id); err != nil {
+			return err
+		}
+	case "repeat":
+		if _, err := recur.Parse(value); err != nil {
+			return err
+		}
+		n.Repeat = [64]byte{}
+		copy(n.Repeat[:], value)
+	default:
+		return fmt.Errorf("unknown note attribute %q", key)
+	}
+	return nil
+}
+
+func decode(content []byte) (group, error) {
+	var (
+		g        group
+		s        = bufio.NewScanner(bytes.NewReader(content))
+		line     = 0
+		inHeader = true
+	)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	fail := func(format string, args ...any) (group, error) {
+		return g, fmt.Errorf("line %v: %s", line, fmt.Sprintf(format, args...))
+	}
+
+	for s.Scan() {
+		line++
+		text := s.Text()
+
+		if line == 1 {
+			if text != textMagic {
+				return fail("not a keep group")
+			}
+			continue
+		}
+
+		if inHeader {
+			if text == separator {
+				inHeader = false
+				continue
+			}
+			key, value, ok := strings.Cut(text, ": ")
+			if !ok {
+				return fail("invalid header line")
+			}
+			if err := decodeHeaderField(&g.Header, key, value); err != nil {
+				return fail("%v", err)
+			}
+			continue
+		}
+
+		n, err := decodeNote(g.Header, text)
+		if err != nil {
+			return fail("%v", err)
+		}
+		g.Notes = append(g.Notes, n)
+	}
+
+	if err := s.Err(); err != nil {
+		return g, err
+	}
+	if inHeader {
+		return g, fmt.Errorf("missing %q after the header", separator)
+	}
+	return g, nil
+}
+
+func decodeHeaderField(h *notes.NoteFileHeader, key, value string) error {
+	switch key {
+	case "title", "description":
+		text, err := strconv.Unquote(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		if key == "title" {
+			h.Title = [20]rune{}
+			copy(h.Title[:], []rune(text))
+		} else {
+			h.Description = [200]rune{}
+			copy(h.Description[:], []rune(text))
+		}
+	case "created":
+		stamp, err := parseStamp(value)
+		if err != nil {
+			return err
+		}
+		h.CreatedAt = stamp
+	case "size-alltime":
+		size, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid size-alltime: %w", err)
+		}
+		h.SizeAlltime = uint32(size)
+	case "flags":
+		flags, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid flags: %w", err)
+		}
+		h.Flags = uint32(flags)
+	case "salt":
+		return decodeHex(h.Salt[:], value)
+	case "key-check":
+		return decodeHex(h.KeyCheck[:], value)
+	default:
+		return fmt.Errorf("unknown header field %q", key)
+	}
+	return nil
+}
+
+func decodeNote(h notes.NoteFileHeader, line string) (notes.Note, error) {
+	var (
+		n   notes.Note
+		err error
+	)
+
+	id, rest, _ := strings.Cut(line, " ")
+	if uid, after, ok := strings.Cut(rest, " "); ok {
+		if u, err := notes.ParseUID(uid); err == nil {
+			n.UID = u
+			rest = after
+		}
+	}
+
+	fields := append([]string{id}, strings.SplitN(rest, " ", 3)...)
+	if len(fields) != 4 {
+		return n, fmt.Errorf("invalid note")
+	}
+
+	n.Id, err = strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || n.Id <= 0 {
+		return n, fmt.Errorf("invalid note id %q", fields[0])
+	}
+	createdAt, err := parseStamp(fields[1])
+	if err != nil {
+		return n, err
+	}
+	color, err := strconv.ParseInt(fields[2], 10, 32)
+	if err != nil {
+		return n, fmt.Errorf("invalid note color %q", fields[2])
+	}
+
+	n.CreatedAt = createdAt
+	n.Color = int32(color)
+
+	for !strings.HasPrefix(fields[3], "\"") && !strings.HasPrefix(fields[3], "enc:") {
+		key, value, rest, err := cutAttr(fields[3])
+		if err != nil {
+			return n, err
+		}
+		if err := decodeAttr(&n, key, value); err != nil {
+			return n, err
+		}
+		fields[3] = rest
+	}
+
+	if h.Encrypted() {
+		parts := strings.Split(strings.TrimPrefix(fields[3], "enc:"), ":")
+		if len(parts) != 3 || !strings.HasPrefix(fields[3], "enc:") {
+			return n, fmt.Errorf("invalid encrypted note")
+		}
+		if err := decodeHex(n.Nonce[:], parts[0]); err != nil {
+			return n, err
+		}
+		if err := decodeHex(n.Tag[:], parts[1]); err != nil {
+			return n, err
+		}
+		ciphertext := make([]byte, 4*len(n.Text))
+		if err := decodeHex(ciphertext, parts[2]); err != nil {
+			return n, err
+		}
+		n.Text = bytesRunes(ciphertext)
+		return n, nil
+	}
+
+	text, err := strconv.Unquote(fields[3])
+	if err != nil {
+		return n, fmt.Errorf("invalid note text: %w", err)
+	}
+	copy(n.Text[:], []rune(text))
+	return n, nil
+}
+
+func decodeHex(dst []byte, value string) error {
+	b, err := hex.DecodeString(value)
+	if err != nil || len(b) != len(dst) {
+		return fmt.Errorf("invalid hex value %q", value)
+	}
+	copy(dst, b)
+	return nil
+}
+
+func formatStamp(unixMilli int64) string {
+	return time.UnixMilli(unixMilli).UTC().Format(stampLayout)
+}
+
+func parseStamp(value string) (int64, error) {
+	t, err := time.Parse(stampLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	return t.UnixMilli(), nil
+}
+
+func runesString(r []rune) string {
+	return strings.TrimRight(string(r), "\x00")
+}
+
+func runesBytes(r []rune) []byte {
+	b := make([]byte, 4*len(r))
+	for i, c := range r {
+		b[4*i] = byte(uint32(c) >> 24)
+		b[4*i+1] = byte(uint32(c) >> 16)
+		b[4*i+2] = byte(uint32(c) >> 8)
+		b[4*i+3] = byte(uint32(c))
+	}
+	return b
+}
+
+func bytesRunes(b []byte) [300]rune {
+	var text [300]rune
+	for i := range text {
+		text[i] = rune(uint32(b[4*i])<<24 | uint32(b[4*i+1])<<16 | uint32(b[4*i+2])<<8 | uint32(b[4*i+3]))
+	}
+	return text
+}
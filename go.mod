@@ -3,11 +3,16 @@ module github.com/DavidEsdrs/keep
 go 1.22.0
 
 require (
-	github.com/fatih/color v1.16.0 // indirect
+	github.com/fatih/color v1.16.0
+	github.com/spf13/cobra v1.8.0
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0
+	golang.org/x/term v0.29.0
+)
+
+require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/spf13/cobra v1.8.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.14.0 // indirect
 )
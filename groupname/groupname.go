@@ -0,0 +1,90 @@
+// Package groupname validates the names of groups, for the notes and the
+// settings naming them alike.
+package groupname
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/DavidEsdrs/keep/common"
+)
+
+// Default is the name of the group of the notes created without group.
+const Default = common.DEFAULT_KEEP_FILE_PATH
+
+// MaxLength is how long each part of a name is, as long as the title in the
+// group header.
+const MaxLength = 20
+
+// MaxDepth is how deep groups can nest.
+const MaxDepth = 8
+
+// MaxPathLength is the length of the longest valid name.
+const MaxPathLength = MaxDepth*(MaxLength+1) - 1
+
+// reserved can't be given to new groups, with why.
+var reserved = map[string]string{
+	"info":  "it names the file keep keeps its state in",
+	Default: "it names the default group",
+}
+
+// Parse validates the name of an existing group, making sure it can only name
+// a file within the keep folder. Groups created before names were validated
+// may hold any other character or be longer than new ones can.
+func Parse(s string) error {
+	switch {
+	case s == "":
+		return fmt.Errorf("empty group name")
+	case !utf8.ValidString(s):
+		return fmt.Errorf("group name %q isn't valid UTF-8", s)
+	case strings.ContainsAny(s, `\:`):
+		return fmt.Errorf("group name %q can't hold backslashes or colons", s)
+	case strings.ContainsFunc(s, unicode.IsControl):
+		return fmt.Errorf("group name %q can't hold control characters", s)
+	case strings.EqualFold(s, "info"):
+		return fmt.Errorf("%q isn't a group, %s", s, reserved["info"])
+	}
+	for _, part := range strings.Split(s, "/") {
+		switch {
+		case part == "":
+			return fmt.Errorf("group name %q has an empty part", s)
+		case strings.HasPrefix(part, "."):
+			return fmt.Errorf("group name %q can't have parts starting with a dot", s)
+		}
+	}
+	return nil
+}
+
+// Check validates the name of a group about to be created or renamed. Each
+// part of a name is made of letters, digits, spaces, dashes, underscores and
+// dots and is up to MaxLength long. Names can't be reserved ones.
+func Check(s string) error {
+	if err := Parse(s); err != nil {
+		return err
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) > MaxDepth {
+		return fmt.Errorf("group name %q nests deeper than %v groups", s, MaxDepth)
+	}
+	for _, part := range parts {
+		if n := utf8.RuneCountInString(part); n > MaxLength {
+			return fmt.Errorf("group name %q has a part longer than %v characters", s, MaxLength)
+		}
+		if strings.TrimSpace(part) != part {
+			return fmt.Errorf("parts of group name %q can't start or end with spaces", s)
+		}
+		for _, r := range part {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_.", r) {
+				return fmt.Errorf("group name %q can't hold %q, only letters, digits, spaces, dashes, underscores, dots and slashes between groups", s, r)
+			}
+		}
+	}
+	for name, why := range reserved {
+		if strings.EqualFold(s, name) {
+			return fmt.Errorf("group name %q is reserved, %s", s, why)
+		}
+	}
+	return nil
+}
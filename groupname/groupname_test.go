@@ -0,0 +1,34 @@
+package groupname_test
+
+import (
+	"testing"
+
+	"github.com/DavidEsdrs/keep/groupname"
+)
+
+func TestCheck(t *testing.T) {
+	for _, name := range []string{"books", "to-do list", "2026_q4", "v1.2", "Bücher", "work/projectA/meetings"} {
+		if err := groupname.Check(name); err != nil {
+			t.Fatalf("valid name %q rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../../tmp/x", "a//b", `a\b`, ".hidden", " books", "books!", "info", "INFO", groupname.Default, "a name way longer than twenty", "a/b/c/d/e/f/g/h/i"} {
+		if err := groupname.Check(name); err == nil {
+			t.Fatalf("invalid name %q accepted", name)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	// names of groups created before names were checked
+	for _, name := range []string{"books!", "a name way longer than twenty", groupname.Default} {
+		if err := groupname.Parse(name); err != nil {
+			t.Fatalf("existing name %q rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../books", "a:b", "info", "a\nb"} {
+		if err := groupname.Parse(name); err == nil {
+			t.Fatalf("invalid name %q accepted", name)
+		}
+	}
+}
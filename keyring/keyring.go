@@ -0,0 +1,151 @@
+// Package keyring finds the passphrase of encrypted groups.
+//
+// The passphrase is looked up, in order, in the KEEP_PASSPHRASE environment
+// variable, in the keyring file and, when keep runs in a terminal, by asking
+// for it.
+//
+// The keyring file lives at KEEP_KEYRING or, by default, at keep/keyring under
+// the user config dir. Each line holds "group = passphrase", where the group
+// "*" matches any group. Blank lines and lines starting with # are ignored.
+// As it holds secrets, it must not be readable by other users.
+package keyring
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var ErrNoPassphrase = errors.New("no passphrase found - set KEEP_PASSPHRASE or add it to the keyring file")
+
+// Passphrase returns the passphrase of the given group.
+func Passphrase(group string) (string, error) {
+	if p, ok := lookup(group); ok {
+		return p, nil
+	}
+	return prompt(fmt.Sprintf("passphrase for group %s: ", group))
+}
+
+// Lookup returns the passphrase of the given group like Passphrase, but never
+// asks for it. It is for the commands no one may be there to answer, such as
+// keep serve.
+func Lookup(group string) (string, error) {
+	if p, ok := lookup(group); ok {
+		return p, nil
+	}
+	return "", ErrNoPassphrase
+}
+
+// NewPassphrase returns the passphrase a new group is encrypted with. When it
+// is asked for, it must be typed twice.
+func NewPassphrase(group string) (string, error) {
+	if p, ok := lookup(group); ok {
+		return p, nil
+	}
+	p, err := prompt(fmt.Sprintf("new passphrase for group %s: ", group))
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	again, err := prompt("repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p != again {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	return p, nil
+}
+
+// Path returns the location of the keyring file.
+func Path() (string, error) {
+	if p := os.Getenv("KEEP_KEYRING"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keep", "keyring"), nil
+}
+
+func lookup(group string) (string, bool) {
+	if p := os.Getenv("KEEP_PASSPHRASE"); p != "" {
+		return p, true
+	}
+	p, err := fromKeyring(group)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return "", false
+	}
+	return p, p != ""
+}
+
+// fromKeyring returns the passphrase of group from the keyring file, or an
+// empty string if there is none.
+func fromKeyring(group string) (string, error) {
+	name, err := Path()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to open keyring: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("keyring %s is accessible by other users, run chmod 600 on it", name)
+	}
+
+	return parse(bufio.NewScanner(f), group)
+}
+
+func parse(s *bufio.Scanner, group string) (string, error) {
+	var wildcard string
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		g, p, ok := strings.Cut(text, "=")
+		if !ok {
+			return "", fmt.Errorf("keyring line %v: expected \"group = passphrase\"", line)
+		}
+		g, p = strings.TrimSpace(g), strings.TrimSpace(p)
+		switch g {
+		case group:
+			return p, nil
+		case "*":
+			wildcard = p
+		}
+	}
+	return wildcard, s.Err()
+}
+
+func prompt(message string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNoPassphrase
+	}
+	fmt.Fprint(os.Stderr, message)
+	p, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(p), err
+}
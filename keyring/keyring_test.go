@@ -0,0 +1,77 @@
+package keyring_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/keyring"
+)
+
+func TestPassphrase(t *testing.T) {
+	ring := filepath.Join(t.TempDir(), "keyring")
+	t.Setenv("KEEP_KEYRING", ring)
+	t.Setenv("KEEP_PASSPHRASE", "")
+
+	content := "# work stuff\nwork = correct horse\n\n* = battery staple\n"
+	if err := os.WriteFile(ring, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Group entry", func(t *testing.T) {
+		p, err := keyring.Passphrase("work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != "correct horse" {
+			t.Fatalf("unexpected passphrase %q", p)
+		}
+	})
+
+	t.Run("Wildcard entry", func(t *testing.T) {
+		p, err := keyring.Passphrase("books")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != "battery staple" {
+			t.Fatalf("unexpected passphrase %q", p)
+		}
+	})
+
+	t.Run("Environment wins", func(t *testing.T) {
+		t.Setenv("KEEP_PASSPHRASE", "from env")
+		p, err := keyring.Passphrase("work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p != "from env" {
+			t.Fatalf("unexpected passphrase %q", p)
+		}
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		p, err := keyring.Lookup("work")
+		if err != nil || p != "correct horse" {
+			t.Fatalf("unexpected passphrase %q %v", p, err)
+		}
+		if err := os.WriteFile(ring, []byte("work = correct horse\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		defer os.WriteFile(ring, []byte(content), 0600)
+		if _, err := keyring.Lookup("books"); !errors.Is(err, keyring.ErrNoPassphrase) {
+			t.Fatalf("expected ErrNoPassphrase, got %v", err)
+		}
+	})
+
+	t.Run("Readable by others", func(t *testing.T) {
+		if err := os.Chmod(ring, 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(ring, 0600)
+		// tests don't run in a terminal, so nothing can be asked for
+		if _, err := keyring.Passphrase("work"); !errors.Is(err, keyring.ErrNoPassphrase) {
+			t.Fatalf("expected ErrNoPassphrase, got %v", err)
+		}
+	})
+}
@@ -1,20 +1,216 @@
 package main
 
 import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/DavidEsdrs/keep/backup"
 	"github.com/DavidEsdrs/keep/common"
 	"github.com/DavidEsdrs/keep/configs"
+	"github.com/DavidEsdrs/keep/dates"
+	"github.com/DavidEsdrs/keep/gitsync"
+	"github.com/DavidEsdrs/keep/keyring"
 	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/query"
+	"github.com/DavidEsdrs/keep/recur"
+	"github.com/DavidEsdrs/keep/server"
+	"github.com/DavidEsdrs/keep/snapshots"
+	"github.com/DavidEsdrs/keep/style"
+	"github.com/DavidEsdrs/keep/timeline"
+	"github.com/DavidEsdrs/keep/tui"
 	"github.com/DavidEsdrs/keep/utils"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+// cfg holds the settings of the config file and the environment.
+var cfg *configs.Config
+
 func init() {
-	configs.GetDefaultGroupState() // starts default notes file when needed
+	profile, err := profileOf(os.Args[1:])
+	if err != nil {
+		exit(usageError{err})
+	}
+	utils.Profile = profile
+	if cfg, err = configs.Load(profile); err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring invalid settings:\n%v\n", err)
+	}
+	if _, err := snapshots.PolicyFromEnv(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid KEEP_SNAPSHOT_RETENTION, no snapshot is pruned: %v\n", err)
+	}
+	applyConfig(cfg)
+
+	notes.BeforeDestroy = snapshots.BeforeDestroy
+	snapshots.Warn = func(err error) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	notes.Passphrase = keyring.Passphrase
+}
+
+// housekeeping brings the store up to date before a command uses it: it creates
+// the store if needed, takes the daily snapshot and adds the notes repeating
+// notes are due to add.
+// Commands that don't use the store, such as help, completion or config, leave
+// it untouched.
+func housekeeping(cmd *cobra.Command, args []string) {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd, "config", "profile":
+			return
+		}
+	}
+	// the store is created on first use
+	kfp, err := utils.GetKeepFilePath()
+	if err == nil {
+		err = os.MkdirAll(kfp, 0755)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create the store: %v\n", err)
+		return
+	}
+	if _, err := snapshots.TakeDaily(); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to take daily snapshot: %v\n", err)
+	}
+	// the notes of encrypted groups wait for keep recurring, rather than
+	// asking for passphrases before every command
+	_, err = notes.MaterializeRecurring(time.Now(), false)
+	if _, locked := err.(*notes.LockedError); err != nil && !locked {
+		fmt.Fprintf(os.Stderr, "unable to add recurring notes: %v\n", err)
+	}
+}
+
+// Exit codes of keep, for scripts to tell failures apart.
+const (
+	exitOK       = 0
+	exitFailure  = 1 // any failure not listed below
+	exitUsage    = 2 // invalid arguments, flags, profile or note reference
+	exitNotFound = 3 // no such group or note
+	exitDeleted  = 4 // the note is deleted
+	exitCorrupt  = 5 // a file of the store is corrupted
+)
+
+// usageError is an error in the arguments or flags a command was given.
+type usageError struct {
+	error
+}
+
+func (e usageError) Unwrap() error {
+	return e.error
+}
+
+// exitCode returns the exit code keep ends with after err.
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage), errors.Is(err, notes.ErrInvalidNoteRef):
+		return exitUsage
+	case errors.Is(err, notes.ErrGroupNotFound), errors.Is(err, notes.ErrNoteNotFound):
+		return exitNotFound
+	case errors.Is(err, notes.ErrNoteDeleted):
+		return exitDeleted
+	case errors.Is(err, notes.ErrCorrupt):
+		return exitCorrupt
+	}
+	return exitFailure
+}
+
+// exit prints err on the standard error and ends keep with its exit code.
+func exit(err error) {
+	fmt.Fprintf(os.Stderr, "keep: %v\n", err)
+	os.Exit(exitCode(err))
+}
+
+// warnLocked prints a warning for the encrypted groups a read over all groups
+// skipped as it couldn't unlock them, and returns any other error.
+func warnLocked(err error) error {
+	var locked *notes.LockedError
+	if errors.As(err, &locked) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", locked)
+		return nil
+	}
+	return err
+}
+
+// markUsageErrors makes the errors of the argument checks of cmd and its
+// subcommands usage errors, as the errors of their flags are.
+func markUsageErrors(cmd *cobra.Command) {
+	if check := cmd.Args; check != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := check(cmd, args); err != nil {
+				return usageError{err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// profileOf returns the profile given by the --profile flag of args, else
+// the active one. It is looked up before the flags are parsed since the store
+// of the profile is opened on start.
+func profileOf(args []string) (string, error) {
+	var profile string
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--profile="); ok {
+			profile = value
+		} else if arg == "--profile" && i+1 < len(args) {
+			profile = args[i+1]
+		}
+	}
+	if profile == "" {
+		active, err := configs.ActiveProfile()
+		if err != nil {
+			return "", err
+		}
+		profile = active
+	}
+	ok, err := configs.ProfileExists(profile)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown profile %s, see `keep profile list`", profile)
+	}
+	return profile, nil
+}
+
+// applyConfig hands the settings to the packages using them.
+func applyConfig(c *configs.Config) {
+	utils.StorePath = utils.ExpandHome(c.Get(configs.StorePath))
+	notes.DateLayout = c.Get(configs.DateFormat)
+	notes.TimeLayout = c.Get(configs.TimeFormat)
+	style.Use(c.Get(configs.Theme))
+	notes.ConfiguredPriorityColors = map[notes.Priority]color.Attribute{}
+	for p, key := range map[notes.Priority]string{
+		notes.PriorityLow:    configs.ColorLow,
+		notes.PriorityNormal: configs.ColorNormal,
+		notes.PriorityHigh:   configs.ColorHigh,
+		notes.PriorityUrgent: configs.ColorUrgent,
+	} {
+		if attr, err := utils.ParseColor(c.Get(key)); err == nil {
+			notes.ConfiguredPriorityColors[p] = attr
+		}
+	}
 }
 
 func main() {
@@ -32,151 +228,482 @@ func main() {
 
 	rootCmd.AddCommand(readFromGroup())
 	rootCmd.AddCommand(readGroups())
+	rootCmd.AddCommand(searchNotes())
+	rootCmd.AddCommand(agenda())
+	rootCmd.AddCommand(recurring())
+	rootCmd.AddCommand(stats())
+	rootCmd.AddCommand(showTimeline())
+
+	// todo
+	rootCmd.AddCommand(markDone())
+	rootCmd.AddCommand(markUndone())
+	rootCmd.AddCommand(pin(true))
+	rootCmd.AddCommand(pin(false))
+	rootCmd.AddCommand(move(false))
+	rootCmd.AddCommand(move(true))
+	rootCmd.AddCommand(browse())
+
+	// backup
+	rootCmd.AddCommand(backupStore())
+	rootCmd.AddCommand(restoreStore())
+	rootCmd.AddCommand(listSnapshots())
+
+	// sync
+	rootCmd.AddCommand(syncNotes())
+
+	// api
+	rootCmd.AddCommand(serve())
+
+	rootCmd.AddCommand(configure())
+	rootCmd.AddCommand(profiles())
+
+	rootCmd.PersistentPreRun = housekeeping
 
 	rootCmd.PersistentFlags().Bool("desc", false, "Show the notes in decreasing order")
+	rootCmd.PersistentFlags().String("profile", utils.Profile, "Use the notes and settings of this profile")
+	rootCmd.PersistentFlags().String("color", cfg.Get(configs.Color), "When to write colors: auto, always or never")
+	rootCmd.RegisterFlagCompletionFunc("color", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"auto", "always", "never"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	rootCmd.PersistentFlags().Bool("no-color", false, "Write no colors, same as --color never")
+	cobra.OnInitialize(func() {
+		flag, _ := rootCmd.PersistentFlags().GetString("color")
+		if noColor, _ := rootCmd.PersistentFlags().GetBool("no-color"); noColor {
+			if rootCmd.PersistentFlags().Changed("color") {
+				exit(usageError{fmt.Errorf("--no-color and --color can't be given together")})
+			}
+			flag = "never"
+		}
+		mode, err := style.ParseMode(flag)
+		if err != nil {
+			exit(usageError{err})
+		}
+		style.Apply(mode)
+	})
+
+	// errors are printed below, pointing to the help of the command for usage
+	// errors rather than printing its whole usage
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	markUsageErrors(rootCmd)
 
-	rootCmd.Execute()
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		fmt.Fprintf(os.Stderr, "keep: %v\n", err)
+		if exitCode(err) == exitUsage {
+			fmt.Fprintf(os.Stderr, "see `%s --help`\n", cmd.CommandPath())
+		}
+		os.Exit(exitCode(err))
+	}
 }
 
 func create() *cobra.Command {
-	return &cobra.Command{
-		Use:   "[group] [note]",
-		Short: "creates a new note",
-		Args:  cobra.RangeArgs(1, 2),
-		Run: func(cmd *cobra.Command, args []string) {
-			if len(args) == 1 {
-				err := notes.CreateSingleNote(args[0])
-				if err != nil {
-					fmt.Println(err)
-				}
-			} else if len(args) == 2 {
-				err := notes.AddNote(args[0], args[1])
-				if err != nil {
-					fmt.Println(err)
-				}
-			} else {
-				panic("invalid args length")
+	cmd := &cobra.Command{
+		Use:               "keep [group] [note]",
+		Short:             "creates a new note",
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeGroups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := noteOptions(cmd)
+			if err != nil {
+				return usageError{err}
 			}
+			if len(args) == 2 {
+				_, err := notes.AddNoteWith(args[0], args[1], opts)
+				return err
+			}
+			return notes.CreateSingleNote(cfg.Get(configs.DefaultGroup), args[0], opts)
 		},
 	}
+	cmd.Flags().String("due", "", "when the note is due, e.g. \"tomorrow 9am\" or 2026-11-01")
+	cmd.Flags().String("remind-before", "", "how long before its due date to be reminded of the note, e.g. 1h")
+	cmd.Flags().String("priority", "normal", "priority of the note: low, normal, high or urgent")
+	cmd.Flags().String("note-color", "", "color of the note, instead of the color of its priority: red, green, yellow, blue, magenta, cyan, white, black or gray")
+	cmd.Flags().String("repeat", "", "repeats the note: daily, weekly, monthly, yearly, \"every 2d\" or a cron expression such as \"0 9 * * MON\"")
+	return cmd
+}
+
+// noteOptions reads the options of a new note from the flags of cmd.
+func noteOptions(cmd *cobra.Command) (notes.NoteOptions, error) {
+	var opts notes.NoteOptions
+
+	if due, _ := cmd.Flags().GetString("due"); due != "" {
+		t, err := dates.Parse(due, time.Now())
+		if err != nil {
+			return opts, err
+		}
+		opts.Due = t
+	}
+	if before, _ := cmd.Flags().GetString("remind-before"); before != "" {
+		d, err := dates.ParseDuration(before)
+		if err != nil {
+			return opts, err
+		}
+		opts.RemindBefore = d
+	}
+	if priority, _ := cmd.Flags().GetString("priority"); priority != "" {
+		p, err := notes.ParsePriority(priority)
+		if err != nil {
+			return opts, err
+		}
+		opts.Priority = p
+	}
+	if name, _ := cmd.Flags().GetString("note-color"); name != "" {
+		c, err := utils.ParseColor(name)
+		if err != nil {
+			return opts, err
+		}
+		opts.Color = c
+	}
+	if repeat, _ := cmd.Flags().GetString("repeat"); repeat != "" {
+		rule, err := recur.Parse(repeat)
+		if err != nil {
+			return opts, err
+		}
+		opts.Repeat = rule
+	}
+
+	return opts, nil
 }
 
 func createGroup() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "group [name] [desc]",
 		Aliases: []string{},
 		Short:   "creates a new note group",
 		Args:    cobra.ExactArgs(2),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			groupName := args[0]
 			description := args[1]
-			_, err := notes.NewNoteFile(groupName, description)
+			encrypt, _ := cmd.Flags().GetBool("encrypt")
+			var err error
+			if encrypt {
+				var passphrase string
+				passphrase, err = keyring.NewPassphrase(groupName)
+				if err != nil {
+					return err
+				}
+				_, err = notes.NewEncryptedNoteFile(groupName, description, passphrase)
+			} else {
+				_, err = notes.NewNoteFile(groupName, description)
+			}
 			if err != nil {
-				panic(err)
+				return err
 			}
 			fmt.Printf("group %s created\n", groupName)
+			return nil
 		},
 	}
+	cmd.Flags().Bool("encrypt", false, "encrypt the notes of the group with a passphrase")
+	cmd.AddCommand(renameGroup())
+	cmd.AddCommand(describeGroup())
+	cmd.AddCommand(createSmartGroup())
+	return cmd
 }
 
-func readFromGroup() *cobra.Command {
+func createSmartGroup() *cobra.Command {
 	return &cobra.Command{
-		Use:     "read [group] [id]",
-		Aliases: []string{},
-		Short:   "read notes from group",
-		Args:    cobra.RangeArgs(1, 2),
-		Run: func(cmd *cobra.Command, args []string) {
+		Use:   "smart [name] [query]",
+		Short: "creates a group showing the notes of all groups matching a query",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := query.NewSmartGroup(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("smart group %s created\n", args[0])
+			return nil
+		},
+	}
+}
+
+func renameGroup() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rename [old] [new]",
+		Short:             "renames a note group",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeGroups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := notes.RenameGroup(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("group %s renamed to %s\n", args[0], args[1])
+			return nil
+		},
+	}
+}
+
+func describeGroup() *cobra.Command {
+	return &cobra.Command{
+		Use:               "describe [name] [desc]",
+		Short:             "changes the description of a note group",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeGroups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := notes.DescribeGroup(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("group %s described\n", args[0])
+			return nil
+		},
+	}
+}
+
+func readFromGroup() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "read [group] [id|uid]",
+		Aliases:           []string{},
+		Short:             "read notes from group",
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeGroupAndNote,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			groupName := args[0]
+			if header, err := notes.GetGroupHeader(groupName); err == nil && header.Smart() && len(args) == 1 {
+				return readSmartGroup(cmd, groupName)
+			}
 			if len(args) == 1 {
-				notes, err := notes.ReadAllNotes(groupName + ".kps")
-				if err != nil {
-					panic(err)
+				groupNames := []string{groupName}
+				if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+					subgroups, err := notes.Subgroups(groupName)
+					if err != nil {
+						return err
+					}
+					groupNames = append(groupNames, subgroups...)
 				}
-				for n := range notes {
-					n.Show()
+				for _, name := range groupNames {
+					notes, err := notes.ReadAllNotes(name)
+					if err != nil {
+						return err
+					}
+					if len(groupNames) > 1 {
+						style.Current.Heading.Println(name)
+					}
+					if _, _, err := showNotes(cmd, name, notes); err != nil {
+						return err
+					}
 				}
 			} else if len(args) == 2 {
-				id, err := strconv.ParseInt(args[1], 10, 64)
-				if err != nil {
-					fmt.Println("id is not a valid number")
-				}
-				note, err := notes.GetNoteById(groupName, id)
+				note, err := notes.GetNote(groupName, args[1])
 				if err != nil {
-					fmt.Println(err.Error())
-					return
+					return err
 				}
 				note.Show()
+				fmt.Printf("uid: %s\n", note.UID)
 			}
+			return nil
 		},
 	}
+	addFilterFlags(cmd)
+	cmd.Flags().BoolP("recursive", "r", false, "also read the notes of the subgroups")
+	return cmd
+}
+
+// readSmartGroup shows the notes of the smart group, under the name of the
+// group each is in.
+func readSmartGroup(cmd *cobra.Command, name string) error {
+	found, err := query.ReadSmartGroup(name)
+	if err := warnLocked(err); err != nil {
+		return err
+	}
+	groupNames := make([]string, 0, len(found))
+	for groupName := range found {
+		groupNames = append(groupNames, groupName)
+	}
+	sort.Strings(groupNames)
+	for _, groupName := range groupNames {
+		ch := make(chan notes.Note, len(found[groupName]))
+		for _, n := range found[groupName] {
+			ch <- n
+		}
+		close(ch)
+		style.Current.Heading.Println(groupName)
+		if _, _, err := showNotes(cmd, groupName, ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFilterFlags adds the flags filtering and sorting the notes shown by
+// showNotes.
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("query", "q", "", "only show the notes matching the query, as in 'tag:fav created:>2026-01-01'")
+	cmd.Flags().Bool("open", false, "only show the notes not done yet")
+	cmd.Flags().Bool("done", false, "only show the notes done")
+	cmd.MarkFlagsMutuallyExclusive("open", "done")
+	cmd.Flags().String("priority", "", "only show the notes of at least this priority: low, normal, high or urgent")
+	cmd.Flags().String("sort", cfg.Get(configs.Sort), "order of the notes after the pinned ones: id or priority")
+}
+
+// filterQuery returns the query the filter flags of cmd amount to, or nil when
+// none is set.
+func filterQuery(cmd *cobra.Command) (query.Expr, error) {
+	var terms []string
+	if q, _ := cmd.Flags().GetString("query"); q != "" {
+		terms = append(terms, "("+q+")")
+	}
+	if open, _ := cmd.Flags().GetBool("open"); open {
+		terms = append(terms, "open")
+	}
+	if done, _ := cmd.Flags().GetBool("done"); done {
+		terms = append(terms, "done")
+	}
+	if p, _ := cmd.Flags().GetString("priority"); p != "" {
+		terms = append(terms, "priority:>="+p)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	return query.Parse(strings.Join(terms, " "))
+}
+
+// showNotes shows the notes of the group passing the filters of cmd in its
+// order and returns how many were shown out of how many were read.
+func showNotes(cmd *cobra.Command, group string, ch <-chan notes.Note) (shown, total int, err error) {
+	var all []notes.Note
+	for n := range ch {
+		all = append(all, n)
+	}
+
+	q, err := filterQuery(cmd)
+	if err != nil {
+		return 0, len(all), usageError{err}
+	}
+
+	var filtered []notes.Note
+	for _, n := range all {
+		if q == nil || q.Match(group, n) {
+			filtered = append(filtered, n)
+		}
+	}
+
+	name, _ := cmd.Flags().GetString("sort")
+	order, err := notes.ParseSortOrder(name)
+	if err != nil {
+		return 0, len(all), usageError{err}
+	}
+	notes.Sort(filtered, order)
+
+	for _, n := range filtered {
+		n.Show()
+	}
+	return len(filtered), len(all), nil
 }
 
 func readAll() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "all",
 		Aliases: []string{"remind", "get"},
 		Short:   "remind you all notes",
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			dir, err := utils.GetKeepFilePath()
 			if err != nil {
-				panic(err)
+				return err
 			}
 			f, err := utils.OpenOrCreate(path.Join(dir, common.DEFAULT_KEEP_FILE_PATH), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600)
 			if err != nil {
-				panic("can't manage to open file! did you deleted it? error: " + err.Error())
+				return fmt.Errorf("can't manage to open file! did you deleted it? error: %w", err)
 			}
 			defer f.Close()
 
 			// TODO: implements --desc flag
 
-			notes, err := notes.ReadAllNotes(common.DEFAULT_KEEP_FILE_PATH)
+			group := cfg.Get(configs.DefaultGroup)
+			all, err := notes.ReadAllNotes(group)
+			// the default group is created along with its first note, until
+			// then it has none
+			if errors.Is(err, notes.ErrGroupNotFound) {
+				fmt.Println("0 notes")
+				return nil
+			}
 			if err != nil {
-				fmt.Println(err)
-				return
+				return err
 			}
 
-			for n := range notes {
-				n.Show()
+			shown, total, err := showNotes(cmd, group, all)
+			if err != nil {
+				return err
 			}
-
-			info := configs.GetDefaultGroupState()
-
-			fmt.Printf("%v notes\n", info.Size)
+			if shown != total {
+				fmt.Printf("%v of %v notes\n", shown, total)
+				return nil
+			}
+			fmt.Printf("%v notes\n", total)
+			return nil
 		},
 	}
+	addFilterFlags(cmd)
+	return cmd
 }
 
 func deleteGroupOrNote() *cobra.Command {
-	return &cobra.Command{
-		Use:   "delete [group] [id]",
-		Short: "delete a given note within a group - if just the group name is group, the group is deleted",
-		Run: func(cmd *cobra.Command, args []string) {
+	cmd := &cobra.Command{
+		Use:               "delete [group] [id|uid]",
+		Short:             "delete a given note within a group - if just the group name is group, the group is deleted",
+		Args:              cobra.RangeArgs(1, 2),
+		ValidArgsFunction: completeGroupAndNote,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			if len(args) == 2 {
 				groupName := args[0]
-				id, err := strconv.ParseInt(args[1], 10, 64)
+				id, err := notes.ResolveNoteRef(groupName, args[1])
 				if err != nil {
-					fmt.Println("invalid id given")
-					return
+					return err
 				}
-				err = notes.DeleteNoteById(groupName, id)
-				if err != nil {
-					fmt.Printf("unable to delete note %v - error: %v", id, err.Error())
-					return
+				if err := notes.DeleteNoteById(groupName, id); err != nil {
+					return fmt.Errorf("unable to delete note %v: %w", id, err)
 				}
-				fmt.Printf("note %v deleted", id)
-			} else if len(args) == 1 {
-				groupName := args[0]
-				err := notes.DeleteGroup(groupName)
+				fmt.Printf("note %v deleted\n", id)
+				return nil
+			}
+
+			groupName := args[0]
+			if yes, _ := cmd.Flags().GetBool("yes"); !yes && cfg.Bool(configs.ConfirmDelete) {
+				ok, err := confirm(fmt.Sprintf("delete group %s and all of its notes?", groupName))
 				if err != nil {
-					fmt.Printf("unable to delete group %v - error: %v", groupName, err.Error())
-					return
+					return err
+				}
+				if !ok {
+					return nil
 				}
-				fmt.Printf("group %v deleted", groupName)
+			}
+			var err error
+			if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+				err = notes.DeleteGroupTree(groupName)
 			} else {
-				panic("invalid args count")
+				err = notes.DeleteGroup(groupName)
+			}
+			if errors.Is(err, notes.ErrHasSubgroups) {
+				return fmt.Errorf("unable to delete group %v: %w - use --recursive to delete them too", groupName, err)
+			}
+			if err != nil {
+				return fmt.Errorf("unable to delete group %v: %w", groupName, err)
 			}
+			fmt.Printf("group %v deleted\n", groupName)
+			return nil
 		},
 	}
+	cmd.Flags().BoolP("recursive", "r", false, "delete the subgroups of the group too")
+	cmd.Flags().BoolP("yes", "y", false, "don't ask for confirmation, even with the confirm.delete setting")
+	return cmd
+}
+
+// confirm asks a yes or no question in the terminal. Outside of one it fails,
+// rather than taking a default answer.
+func confirm(question string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("not asking for confirmation outside a terminal, use --yes")
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
 }
 
 func delete() *cobra.Command {
@@ -184,45 +711,963 @@ func delete() *cobra.Command {
 		Use:   "remove [id]",
 		Short: "removes a given note from default file",
 		Args:  cobra.ExactArgs(1),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return usageError{fmt.Errorf("%q is not a note id", args[0])}
+			}
+
 			dir, err := utils.GetKeepFilePath()
 			if err != nil {
-				panic(err)
+				return err
 			}
 			file, err := utils.OpenOrCreate(path.Join(dir, common.DEFAULT_KEEP_FILE_PATH), os.O_CREATE|os.O_RDWR, 0600)
 			if err != nil {
-				panic(err)
+				return err
 			}
 			defer file.Close()
 
-			id, _ := strconv.ParseInt(args[0], 10, 64)
-
-			if err := notes.DeleteNoteById(common.DEFAULT_KEEP_FILE_PATH, id); err != nil {
-				fmt.Println("unable to delete note with given id")
-				return
+			group := cfg.Get(configs.DefaultGroup)
+			if err := notes.DeleteNoteById(group, id); err != nil {
+				return fmt.Errorf("unable to delete note %v: %w", id, err)
+			}
+			if group != common.DEFAULT_KEEP_FILE_PATH {
+				return nil
 			}
 
-			info := configs.GetDefaultGroupState()
-
+			info, err := configs.GetDefaultGroupState()
+			if err != nil {
+				return err
+			}
 			info.Remove()
-			info.Save()
+			return info.Save()
 		},
 	}
 }
 
 func readGroups() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "get all groups created",
-		Run: func(cmd *cobra.Command, args []string) {
-			groups, err := notes.GetGroups()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := notes.GroupNames()
 			if err != nil {
-				fmt.Println(err.Error())
-				return
+				return err
 			}
-			for _, g := range groups {
-				g.Show()
+			fmt.Printf("profile %s\n", utils.Profile)
+			if tree, _ := cmd.Flags().GetBool("tree"); tree {
+				showTree(names)
+				return nil
+			}
+			// a group failing to open doesn't hide the others
+			var errs []error
+			for _, name := range names {
+				g, err := notes.GetGroupHeader(name)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				if g.Smart() {
+					g.ShowVirtual(name)
+					continue
+				}
+				done, total, err := notes.Progress(name)
+				if err != nil {
+					g.Show()
+					continue
+				}
+				g.ShowWithProgress(name, done, total)
+			}
+			return errors.Join(errs...)
+		},
+	}
+	cmd.Flags().Bool("tree", false, "show groups within their parents, with how many notes each holds")
+	return cmd
+}
+
+// showTree shows the groups as a tree of subgroups.
+func showTree(names []string) {
+	children := map[string][]string{}
+	isGroup := map[string]bool{}
+	var add func(name string)
+	add = func(name string) {
+		if isGroup[name] {
+			return
+		}
+		isGroup[name] = true
+		parent, _ := notes.GroupName(name).Parent()
+		if parent != "" {
+			// parents synced away before their subgroups are shown too
+			add(string(parent))
+		}
+		children[string(parent)] = append(children[string(parent)], name)
+	}
+	for _, name := range names {
+		add(name)
+	}
+
+	var show func(parent, indent string)
+	show = func(parent, indent string) {
+		kids := children[parent]
+		sort.Strings(kids)
+		for i, name := range kids {
+			branch, next := "├── ", "│   "
+			if i == len(kids)-1 {
+				branch, next = "└── ", "    "
+			}
+			if parent == "" {
+				branch, next = "", ""
+			}
+			label := notes.GroupName(name).Base()
+			if h, err := notes.GetGroupHeader(name); err == nil && h.Smart() {
+				label += " (virtual)"
+			} else if err == nil {
+				label += fmt.Sprintf(" (%v)", h.Size)
+			}
+			fmt.Println(indent + branch + label)
+			show(name, indent+next)
+		}
+	}
+	show("", "")
+}
+
+func searchNotes() *cobra.Command {
+	return &cobra.Command{
+		Use:   "search [query]",
+		Short: "search notes matching the given query in all groups",
+		Long: `search notes matching the given query in all groups, such as
+'group:books tag:fav created:>2026-01-01 "exact phrase" -excluded color:red id:10..20'`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := query.Parse(strings.Join(args, " "))
+			if err != nil {
+				return usageError{err}
+			}
+			found, err := notes.Collect(q.Match)
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+			groups := make([]string, 0, len(found))
+			for group := range found {
+				groups = append(groups, group)
+			}
+			sort.Strings(groups)
+			for _, group := range groups {
+				fmt.Printf("%s:\n", group)
+				for _, n := range found[group] {
+					n.Show()
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func backupStore() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "saves all groups into a single archive",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+			if output == "" {
+				output = fmt.Sprintf("keep-%s.tar.gz", time.Now().Format("2006-01-02"))
+			}
+			f, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			manifest, err := backup.Create(f)
+			if err != nil {
+				os.Remove(output)
+				return fmt.Errorf("unable to backup: %w", err)
+			}
+			fmt.Printf("%v files saved into %s\n", len(manifest.Files), output)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "", "archive to write (default keep-<date>.tar.gz)")
+	return cmd
+}
+
+func restoreStore() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore [archive]",
+		Short: "restores groups from an archive made by backup",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, _ := cmd.Flags().GetString("mode")
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			manifest, err := backup.Restore(f, backup.Mode(mode))
+			if err != nil {
+				return fmt.Errorf("unable to restore %s: %w", args[0], err)
+			}
+			fmt.Printf("%v files restored from %s (%s)\n", len(manifest.Files), args[0], mode)
+			return nil
+		},
+	}
+	cmd.Flags().String("mode", string(backup.Merge), "merge into the current notes or replace them (merge|replace)")
+	return cmd
+}
+
+func listSnapshots() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "list the snapshots taken automatically before deletes and once a day",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			snaps, err := snapshots.List()
+			if err != nil {
+				return err
+			}
+			for _, s := range snaps {
+				fmt.Printf("%s ~ %s %s %v bytes\n", s.Name, s.CreatedAt.Local().Format("01/02/2006 15:04"), s.Kind, s.Size)
+			}
+			return nil
+		},
+	}
+
+	restore := &cobra.Command{
+		Use:   "restore [snapshot]",
+		Short: "restores notes from a snapshot",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, _ := cmd.Flags().GetString("mode")
+			if err := snapshots.Restore(args[0], backup.Mode(mode)); err != nil {
+				return fmt.Errorf("unable to restore %s: %w", args[0], err)
+			}
+			fmt.Printf("snapshot %s restored\n", args[0])
+			return nil
+		},
+	}
+	restore.Flags().String("mode", string(backup.Merge), "merge into the current notes or replace them (merge|replace)")
+
+	prune := &cobra.Command{
+		Use:   "prune",
+		Short: "removes the snapshots out of the retention policy",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := snapshots.Prune()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%v snapshots removed\n", len(removed))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(restore, prune)
+	return cmd
+}
+
+func syncNotes() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "keep notes in sync between machines through a git repository",
+	}
+
+	initSync := &cobra.Command{
+		Use:   "init [remote]",
+		Short: "sets the git remote to sync with and pulls its notes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := gitsync.Init(args[0]); err != nil {
+				return fmt.Errorf("unable to set up sync: %w", err)
+			}
+			fmt.Printf("syncing with %s\n", args[0])
+			return nil
+		},
+	}
+
+	push := &cobra.Command{
+		Use:   "push",
+		Short: "sends local notes to the remote",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := gitsync.Push(); err != nil {
+				return err
+			}
+			fmt.Println("notes pushed")
+			return nil
+		},
+	}
+
+	pull := &cobra.Command{
+		Use:   "pull",
+		Short: "merges notes from the remote into the local ones",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			merged, err := gitsync.Pull()
+			if err != nil {
+				return err
+			}
+			for _, group := range merged {
+				fmt.Printf("group %s changed on both sides, notes edited on both were kept twice\n", group)
+			}
+			fmt.Println("notes pulled")
+			return nil
+		},
+	}
+
+	cmd.AddCommand(initSync, push, pull)
+	return cmd
+}
+
+func serve() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serves the notes through a local HTTP/JSON API",
+		Long: `serves the notes through a local HTTP/JSON API, described by the OpenAPI
+document at /openapi.json. Requests must bear the token in the header
+"Authorization: Bearer <token>". The token is taken from --token, then from
+KEEP_API_TOKEN, and otherwise generated and printed on start.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addr, _ := cmd.Flags().GetString("addr")
+			token, _ := cmd.Flags().GetString("token")
+			if token == "" {
+				token = os.Getenv("KEEP_API_TOKEN")
+			}
+			if token == "" {
+				b := make([]byte, 24)
+				if _, err := rand.Read(b); err != nil {
+					return fmt.Errorf("unable to generate token: %w", err)
+				}
+				token = hex.EncodeToString(b)
+				fmt.Printf("token: %s\n", token)
+			}
+			// a request must not hang on a prompt in the terminal keep was
+			// started from: encrypted groups need KEEP_PASSPHRASE or the
+			// keyring file, or are answered with 403
+			notes.Passphrase = keyring.Lookup
+			fmt.Printf("serving on http://%s\n", addr)
+			srv := &http.Server{
+				Addr:    addr,
+				Handler: server.New(token),
+				// slow or stalled clients can't hold connections forever
+				ReadHeaderTimeout: 5 * time.Second,
+				ReadTimeout:       15 * time.Second,
+				WriteTimeout:      30 * time.Second,
+				IdleTimeout:       time.Minute,
+			}
+			return srv.ListenAndServe()
+		},
+	}
+	cmd.Flags().String("addr", "127.0.0.1:7777", "address to listen on")
+	cmd.Flags().String("token", "", "token the requests must bear")
+	return cmd
+}
+
+func browse() *cobra.Command {
+	return &cobra.Command{
+		Use:   "tui",
+		Short: "browses and edits the notes in a full-screen interface",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tui.Run()
+		},
+	}
+}
+
+// completeGroups completes the first argument with the names of the groups.
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return groupCompletions(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeGroupAndNote completes the name of a group and then the id of one of
+// its notes.
+func completeGroupAndNote(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return groupCompletions(), cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return noteCompletions(args[0]), cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// groupCompletions returns the group names described by their description.
+func groupCompletions() []string {
+	names, err := notes.GroupNames()
+	if err != nil {
+		return nil
+	}
+	var completions []string
+	for _, name := range names {
+		h, err := notes.GetGroupHeader(name)
+		if err != nil {
+			continue
+		}
+		completions = append(completions, name+"\t"+preview(string(h.Description[:])))
+	}
+	return completions
+}
+
+// noteCompletions returns the ids of the notes of a group described by the
+// start of their text. Notes of encrypted groups aren't decrypted, as no
+// passphrase can be asked for while completing.
+func noteCompletions(groupName string) []string {
+	header, groupNotes, err := notes.ReadGroup(groupName)
+	if err != nil {
+		return nil
+	}
+	var completions []string
+	for _, n := range groupNotes {
+		description := preview(n.String())
+		if header.Encrypted() {
+			description = "encrypted"
+		}
+		completions = append(completions, fmt.Sprintf("%v\t%s", n.Id, description))
+	}
+	return completions
+}
+
+// preview returns the first words of a text on a single line.
+func preview(text string) string {
+	text = strings.Join(strings.Fields(strings.TrimRight(text, "\x00")), " ")
+	if r := []rune(text); len(r) > 50 {
+		return string(r[:49]) + "…"
+	}
+	return text
+}
+
+func agenda() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "due",
+		Short: "shows the notes that are overdue, due today and upcoming",
+		Long: `shows the notes of all groups that have a due date and aren't done, split
+into overdue, due today and upcoming ones.
+
+With --reminders only the notes whose reminder is due, i.e. that are due within
+their --remind-before, are printed, one per line as
+"<group>\t<id>\t<uid>\t<due>\t<text>" to be used by other tools.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			found, err := notes.DueNotes()
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+
+			type dueNote struct {
+				group string
+				note  notes.Note
+			}
+			var all []dueNote
+			for group, groupNotes := range found {
+				for _, n := range groupNotes {
+					all = append(all, dueNote{group, n})
+				}
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].note.Due < all[j].note.Due })
+
+			now := time.Now()
+
+			if reminders, _ := cmd.Flags().GetBool("reminders"); reminders {
+				for _, d := range all {
+					if !d.note.RemindTime().After(now) && d.note.DueTime().After(now) {
+						fmt.Printf("%s\t%v\t%s\t%s\t%s\n", d.group, d.note.Id, d.note.UID, d.note.DueTime().Format(time.RFC3339), d.note.String())
+					}
+				}
+				return nil
+			}
+
+			var overdue, today, upcoming []dueNote
+			for _, d := range all {
+				switch due := d.note.DueTime(); {
+				case due.Before(now):
+					overdue = append(overdue, d)
+				case dates.SameDay(now, due):
+					today = append(today, d)
+				default:
+					upcoming = append(upcoming, d)
+				}
+			}
+
+			for _, section := range []struct {
+				title string
+				notes []dueNote
+			}{{"overdue", overdue}, {"today", today}, {"upcoming", upcoming}} {
+				if len(section.notes) == 0 {
+					continue
+				}
+				fmt.Printf("%s:\n", section.title)
+				for _, d := range section.notes {
+					fmt.Printf("%s ", d.group)
+					d.note.Show()
+				}
+			}
+			if len(all) == 0 {
+				fmt.Println("nothing is due")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool("reminders", false, "only print the notes to be reminded of now, tab separated")
+	return cmd
+}
+
+func recurring() *cobra.Command {
+	return &cobra.Command{
+		Use:   "recurring",
+		Short: "lists the repeating notes and their rules",
+		Long: `lists the repeating notes and their rules. It first adds the instances
+of the repeating notes of encrypted groups that came, which are left out
+whenever keep runs as they need the passphrase.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := notes.MaterializeRecurring(time.Now(), true)
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+			found, err := notes.RecurringNotes()
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+
+			groups := make([]string, 0, len(found))
+			for group := range found {
+				groups = append(groups, group)
+			}
+			sort.Strings(groups)
+
+			for _, group := range groups {
+				for _, n := range found[group] {
+					rule, err := n.RepeatRule()
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "%s %v: %v\n", group, n.Id, err)
+						continue
+					}
+					next := rule.Next(n.DueTime())
+					fmt.Printf("%s %v %q repeats %s, next on %s\n", group, n.Id, n.String(), rule, next.Local().Format("Mon Jan 2 15:04"))
+				}
+			}
+			if len(groups) == 0 {
+				fmt.Println("no repeating notes")
+			}
+			return nil
+		},
+	}
+}
+
+func configure() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "shows and changes the settings of keep",
+		Long: `shows and changes the settings of keep, stored in the config file at
+$KEEP_CONFIG or, by default, keep/config.toml under the user config dir (e.g.
+~/.config/keep/config.toml).
+
+Environment variables override the file: each setting has its own, such as
+KEEP_DISPLAY_THEME for display.theme. Flags override both.`,
+	}
+
+	completeKeys := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return configs.Keys(), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "lists all settings, their value and where it comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, s := range configs.Settings {
+				fmt.Printf("%-20s %-24q %s\n", s.Key, cfg.Get(s.Key), cfg.Source(s.Key))
+			}
+			return nil
+		},
+	}
+
+	get := &cobra.Command{
+		Use:               "get [key]",
+		Short:             "prints the value of a setting",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := configs.Lookup(args[0]); err != nil {
+				return usageError{err}
+			}
+			fmt.Println(cfg.Get(args[0]))
+			return nil
+		},
+	}
+
+	set := &cobra.Command{
+		Use:               "set [key] [value]",
+		Short:             "changes a setting in the config file",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeKeys,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, _ := configs.Path()
+			if inProfile, _ := cmd.Flags().GetBool("in-profile"); inProfile {
+				if err := configs.SetInProfile(utils.Profile, args[0], args[1]); err != nil {
+					return err
+				}
+				p, _ = configs.ProfilePath(utils.Profile)
+			} else if err := configs.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("%s set to %q in %s\n", args[0], args[1], p)
+			if s, _ := configs.Lookup(args[0]); cfg.Source(args[0]) == s.Env() {
+				fmt.Printf("%s overrides it while set\n", s.Env())
+			}
+			return nil
+		},
+	}
+
+	set.Flags().Bool("in-profile", false, "change the setting for the profile in use only")
+
+	cmd.AddCommand(list, get, set)
+	return cmd
+}
+
+func profiles() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "manages the profiles, each with its own notes and settings",
+		Long: `manages the profiles, each with its own notes and settings.
+
+The notes of a profile are stored in ~/.keep-<profile> unless its store_path
+says otherwise, and its settings, kept in the profiles folder next to the
+config file, override the ones of the config file. The default profile uses
+~/.keep and the config file alone.
+
+The profile in use is the one of the --profile flag, else the one of
+$KEEP_PROFILE, else the one last chosen with ` + "`keep profile use`" + `.`,
+	}
+
+	completeProfiles := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names, _ := configs.Profiles()
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	add := &cobra.Command{
+		Use:   "add [name]",
+		Short: "creates a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _ := cmd.Flags().GetString("store")
+			if err := configs.AddProfile(args[0], store); err != nil {
+				return err
+			}
+			fmt.Printf("profile %s created, switch to it with `keep profile use %s`\n", args[0], args[0])
+			return nil
+		},
+	}
+	add.Flags().String("store", "", "folder storing the notes of the profile")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "lists the profiles, marking the one in use",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names, err := configs.Profiles()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				c := cfg
+				if name != utils.Profile {
+					// invalid settings are reported when the profile is used
+					c, _ = configs.Load(name)
+				}
+				mark := " "
+				if name == utils.Profile {
+					mark = "*"
+				}
+				fmt.Printf("%s %-16s %s\n", mark, name, utils.ExpandHome(c.Get(configs.StorePath)))
+			}
+			return nil
+		},
+	}
+
+	use := &cobra.Command{
+		Use:               "use [name]",
+		Short:             "switches to a profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := configs.UseProfile(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("using profile %s\n", args[0])
+			if env := os.Getenv("KEEP_PROFILE"); env != "" && env != args[0] {
+				fmt.Printf("KEEP_PROFILE overrides it while set\n")
+			}
+			return nil
+		},
+	}
+
+	remove := &cobra.Command{
+		Use:               "remove [name]",
+		Short:             "removes a profile, leaving its notes in place",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfiles,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := configs.RemoveProfile(args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("profile %s removed, its notes are left in place\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(add, list, use, remove)
+	return cmd
+}
+
+func stats() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "stats [group]",
+		Short:             "shows statistics of a group, or of all groups",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeGroups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+			if output != "text" && output != "json" {
+				return usageError{fmt.Errorf("unknown output %q, expected text or json", output)}
+			}
+
+			groupNames := args
+			if len(args) == 0 {
+				var err error
+				if groupNames, err = notes.GroupNames(); err != nil {
+					return err
+				}
+			}
+			s, err := notes.GroupStats(groupNames...)
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(s)
+			}
+			showStats(s)
+			return nil
+		},
+	}
+	cmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	return cmd
+}
+
+func showTimeline() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "timeline [group]",
+		Short: "shows when notes were taken",
+		Long: `shows a heatmap of the notes taken each day in all groups, or in the given
+group and its subgroups.
+
+With --list the notes are listed instead, oldest first, under the day, week or
+month they were taken in.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeGroups,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			by, _ := cmd.Flags().GetString("by")
+			period, err := timeline.ParsePeriod(by)
+			if err != nil {
+				return usageError{err}
+			}
+			weeks, _ := cmd.Flags().GetInt("weeks")
+			if weeks < 1 {
+				return usageError{fmt.Errorf("--weeks must be at least 1")}
+			}
+
+			found, err := notes.Collect(func(group string, _ notes.Note) bool {
+				return len(args) == 0 || notes.GroupName(args[0]).Contains(notes.GroupName(group))
+			})
+			if err := warnLocked(err); err != nil {
+				return err
+			}
+			entries := timeline.Entries(found)
+
+			if list, _ := cmd.Flags().GetBool("list"); list {
+				if len(entries) == 0 {
+					fmt.Println("no notes")
+					return nil
+				}
+				timeline.List(os.Stdout, entries, period, time.Local)
+				return nil
+			}
+			timeline.Heatmap(os.Stdout, entries, time.Now(), weeks)
+			return nil
+		},
+	}
+	cmd.Flags().Bool("list", false, "list the notes under the period they were taken in")
+	cmd.Flags().String("by", "day", "period the listed notes are grouped by: day, week or month")
+	cmd.Flags().Int("weeks", 53, "weeks shown by the heatmap")
+	return cmd
+}
+
+// showStats prints the statistics for people to read.
+func showStats(s notes.Stats) {
+	fmt.Printf("groups       %v\n", s.Groups)
+	fmt.Printf("notes        %v live, %v deleted, %v added in all, %v done\n", s.Live, s.Deleted, s.Total, s.Done)
+	fmt.Printf("rate         %.1f per day, %.1f per week\n", s.PerDay, s.PerWeek)
+	for _, n := range []struct {
+		title string
+		note  *notes.NoteStat
+	}{{"oldest", s.Oldest}, {"newest", s.Newest}} {
+		if n.note != nil {
+			fmt.Printf("%-12s %s %s %v: %s\n", n.title, n.note.CreatedAt.Local().Format("2006-01-02"), n.note.Group, n.note.Id, preview(n.note.Text))
+		}
+	}
+	fmt.Printf("on disk      %s, %s reclaimable\n", formatBytes(s.Bytes), formatBytes(s.Reclaimable))
+
+	if len(s.TopTags) > 0 {
+		tags := make([]string, len(s.TopTags))
+		for i, t := range s.TopTags {
+			tags[i] = fmt.Sprintf("#%s (%v)", t.Tag, t.Count)
+		}
+		fmt.Printf("top tags     %s\n", strings.Join(tags, ", "))
+	}
+
+	fmt.Println("lengths")
+	most := 0
+	for _, b := range s.Lengths {
+		most = max(most, b.Count)
+	}
+	for _, b := range s.Lengths {
+		bar := 0
+		if most > 0 {
+			bar = (b.Count*30 + most - 1) / most
+		}
+		fmt.Printf("  %3v-%-3v    %s %v\n", b.Min, b.Max, strings.Repeat("█", bar), b.Count)
+	}
+}
+
+// formatBytes formats a size in bytes with a binary unit, as in "4.2 KiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%v B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func markDone() *cobra.Command {
+	return &cobra.Command{
+		Use:               "done [group] [id|uid]",
+		Short:             "marks a note as done",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeGroupAndNote,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupName := args[0]
+			id, err := notes.ResolveNoteRef(groupName, args[1])
+			if err != nil {
+				return err
+			}
+			next, err := notes.MarkDone(groupName, id)
+			if err != nil {
+				return fmt.Errorf("unable to mark note %v as done: %w", id, err)
+			}
+			fmt.Printf("note %v done\n", id)
+			if next != 0 {
+				fmt.Printf("next one added as note %v\n", next)
+			}
+			return nil
+		},
+	}
+}
+
+func markUndone() *cobra.Command {
+	return &cobra.Command{
+		Use:               "undone [group] [id|uid]",
+		Short:             "marks a note as not done",
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeGroupAndNote,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupName := args[0]
+			id, err := notes.ResolveNoteRef(groupName, args[1])
+			if err != nil {
+				return err
+			}
+			if err := notes.MarkUndone(groupName, id); err != nil {
+				return fmt.Errorf("unable to mark note %v as not done: %w", id, err)
+			}
+			fmt.Printf("note %v not done\n", id)
+			return nil
+		},
+	}
+}
+
+// pin returns the pin command, or the unpin one.
+func pin(pinned bool) *cobra.Command {
+	use, short, done := "pin", "lists a note before the rest", "pinned"
+	if !pinned {
+		use, short, done = "unpin", "stops listing a note before the rest", "unpinned"
+	}
+	return &cobra.Command{
+		Use:               use + " [group] [id|uid]",
+		Short:             short,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeGroupAndNote,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			groupName := args[0]
+			id, err := notes.ResolveNoteRef(groupName, args[1])
+			if err != nil {
+				return err
+			}
+			if err := notes.SetPinned(groupName, id, pinned); err != nil {
+				return fmt.Errorf("unable to %s note %v: %w", use, id, err)
+			}
+			fmt.Printf("note %v %s\n", id, done)
+			return nil
+		},
+	}
+}
+
+// move returns the mv command, or the cp one.
+func move(copying bool) *cobra.Command {
+	use, short, done := "mv", "moves a note to another group", "moved"
+	if copying {
+		use, short, done = "cp", "copies a note to another group", "copied"
+	}
+	cmd := &cobra.Command{
+		Use:   use + " [src-group] [id|uid] [dst-group]",
+		Short: short,
+		Args:  cobra.ExactArgs(3),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 2 {
+				return groupCompletions(), cobra.ShellCompDirectiveNoFileComp
+			}
+			return completeGroupAndNote(cmd, args, toComplete)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, to := args[0], args[2]
+			id, err := notes.ResolveNoteRef(from, args[1])
+			if err != nil {
+				return err
+			}
+			ref, _ := cmd.Flags().GetBool("ref")
+			note, err := notes.MoveNote(from, id, to, notes.MoveOptions{Copy: copying, Ref: ref})
+			if err != nil {
+				return fmt.Errorf("unable to %s note %v: %w", use, id, err)
 			}
+			fmt.Printf("note %v %s to %s as note %v\n", id, done, to, note.Id)
+			return nil
 		},
 	}
+	cmd.Flags().Bool("ref", false, "record on the note the group and id it came from")
+	return cmd
 }
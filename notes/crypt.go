@@ -0,0 +1,202 @@
+package notes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// FlagEncrypted marks a group whose note texts are encrypted with a key derived
+// from a passphrase. Titles, descriptions and note metadata stay readable.
+const FlagEncrypted uint32 = 1 << 0
+
+// FlagBound marks an encrypted group whose note texts are sealed along with
+// the UID of their note, so a text can't be passed off as the text of another
+// note. Groups encrypted before it came along go on without it.
+const FlagBound uint32 = 1 << 2
+
+// argon2id parameters used to derive group keys
+const (
+	kdfTime    = 1
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	keySize    = 32
+)
+
+// Passphrase, when set, is asked for the passphrase of an encrypted group
+// whenever its notes are read or written.
+var Passphrase func(groupName string) (string, error)
+
+var (
+	keysMu sync.Mutex
+	keys   = map[[16]byte][]byte{} // derived keys by group salt
+)
+
+func deriveKey(passphrase string, salt [16]byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt[:], kdfTime, kdfMemory, kdfThreads, keySize)
+}
+
+// keyCheck is stored in the header of encrypted groups to tell a wrong
+// passphrase apart from the right one without holding the key itself.
+func keyCheck(key []byte) [32]byte {
+	return sha256.Sum256(append([]byte("keep key check\x00"), key...))
+}
+
+func (n *NoteFileHeader) Encrypted() bool {
+	return n.Flags&FlagEncrypted != 0
+}
+
+// encrypt marks the header as encrypted with a key derived from passphrase.
+func (n *NoteFileHeader) encrypt(passphrase string) error {
+	if _, err := rand.Read(n.Salt[:]); err != nil {
+		return err
+	}
+	key := deriveKey(passphrase, n.Salt)
+	n.Flags |= FlagEncrypted | FlagBound
+	n.KeyCheck = keyCheck(key)
+
+	keysMu.Lock()
+	keys[n.Salt] = key
+	keysMu.Unlock()
+	return nil
+}
+
+// unlock derives the key of an encrypted group, asking for its passphrase. It
+// does nothing for plain groups.
+func (g *groupFile) unlock() error {
+	if !g.header.Encrypted() || g.key != nil {
+		return nil
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if key, ok := keys[g.header.Salt]; ok {
+		g.key = key
+		return nil
+	}
+
+	if Passphrase == nil {
+		return fmt.Errorf("%w: %s is encrypted and no passphrase was given", ErrLocked, g.name)
+	}
+	passphrase, err := Passphrase(g.name)
+	if err != nil {
+		return fmt.Errorf("%w: unable to get passphrase of group %s: %w", ErrLocked, g.name, err)
+	}
+
+	key := deriveKey(passphrase, g.header.Salt)
+	check := keyCheck(key)
+	if subtle.ConstantTimeCompare(check[:], g.header.KeyCheck[:]) != 1 {
+		return fmt.Errorf("%w: wrong passphrase for group %s", ErrLocked, g.name)
+	}
+
+	keys[g.header.Salt] = key
+	g.key = key
+	return nil
+}
+
+// seal encrypts the text of a note to be written into the group.
+func (g *groupFile) seal(n *Note) error {
+	if !g.header.Encrypted() {
+		return nil
+	}
+	if err := g.unlock(); err != nil {
+		return err
+	}
+	gcm, err := newGCM(g.key)
+	if err != nil {
+		return err
+	}
+	if _, err := rand.Read(n.Nonce[:]); err != nil {
+		return err
+	}
+	plain := textBytes(n.Text)
+	sealed := gcm.Seal(nil, n.Nonce[:], plain, g.additionalData(n))
+	n.Text = textRunes(sealed[:len(plain)])
+	copy(n.Tag[:], sealed[len(plain):])
+	return nil
+}
+
+// open decrypts the text of a note read from the group.
+func (g *groupFile) open(n *Note) error {
+	if !g.header.Encrypted() || n.Id <= 0 {
+		return nil
+	}
+	if err := g.unlock(); err != nil {
+		return err
+	}
+	gcm, err := newGCM(g.key)
+	if err != nil {
+		return err
+	}
+	sealed := append(textBytes(n.Text), n.Tag[:]...)
+	plain, err := gcm.Open(nil, n.Nonce[:], sealed, g.additionalData(n))
+	if err != nil {
+		return fmt.Errorf("%w: note %v of group %s fails to decrypt", ErrCorrupt, n.Id, g.name)
+	}
+	n.Text = textRunes(plain)
+	n.Nonce = [12]byte{}
+	n.Tag = [16]byte{}
+	return nil
+}
+
+// additionalData returns what the text of a note is sealed along with.
+func (g *groupFile) additionalData(n *Note) []byte {
+	if g.header.Flags&FlagBound == 0 {
+		return nil
+	}
+	return n.UID[:]
+}
+
+// rebind gives a note, as stored, another UID. The text of notes of bound
+// groups is decrypted and encrypted again, as it is sealed along with the UID.
+func (g *groupFile) rebind(n *Note, uid UID) error {
+	if g.header.Flags&FlagBound == 0 {
+		n.UID = uid
+		return nil
+	}
+	if err := g.open(n); err != nil {
+		return err
+	}
+	n.UID = uid
+	return g.seal(n)
+}
+
+// Rebind gives a note of the group with the given header, as stored, another
+// UID. Notes of encrypted groups are encrypted again, which asks for the
+// passphrase of the group.
+func Rebind(groupName string, header NoteFileHeader, n *Note, uid UID) error {
+	g := &groupFile{name: groupName, header: header}
+	return g.rebind(n, uid)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func textBytes(text [300]rune) []byte {
+	b := make([]byte, 4*len(text))
+	for i, r := range text {
+		binary.BigEndian.PutUint32(b[4*i:], uint32(r))
+	}
+	return b
+}
+
+func textRunes(b []byte) [300]rune {
+	var text [300]rune
+	for i := range text {
+		text[i] = rune(binary.BigEndian.Uint32(b[4*i:]))
+	}
+	return text
+}
@@ -0,0 +1,72 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors the functions of the package wrap, to be told apart with errors.Is.
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrNoteNotFound  = errors.New("note not found")
+	ErrNoteDeleted   = errors.New("note is deleted")
+	ErrCorrupt       = errors.New("corrupted data")
+	// ErrLocked is returned for an encrypted group whose passphrase is
+	// missing or wrong.
+	ErrLocked = errors.New("group is locked")
+	// ErrInvalidNoteRef is returned for a note reference that is neither an
+	// id nor a UID, before any group is looked at.
+	ErrInvalidNoteRef = errors.New("invalid note reference")
+)
+
+// LockedError is returned by the reads over all groups that skipped the
+// encrypted groups they couldn't unlock. What they return is complete for the
+// other groups.
+type LockedError struct {
+	Groups []string
+	Errs   []error
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("skipped the encrypted groups %s: %v", strings.Join(e.Groups, ", "), errors.Join(e.Errs...))
+}
+
+func (e *LockedError) Unwrap() []error {
+	return e.Errs
+}
+
+// skip records that a group was skipped, if err tells it is locked, and
+// reports whether it did.
+func (e *LockedError) skip(group string, err error) bool {
+	if !errors.Is(err, ErrLocked) {
+		return false
+	}
+	e.Groups = append(e.Groups, group)
+	e.Errs = append(e.Errs, err)
+	return true
+}
+
+// orNil returns e, or nil if no group was skipped.
+func (e *LockedError) orNil() error {
+	if len(e.Groups) == 0 {
+		return nil
+	}
+	return e
+}
+
+// readLiveNote is like readNote, failing with ErrNoteDeleted for deleted
+// notes.
+func (g *groupFile) readLiveNote(id int64) (Note, error) {
+	n, err := g.readNote(id)
+	if err != nil {
+		return n, err
+	}
+	if n.Id <= 0 {
+		return n, fmt.Errorf("%w: %v in group %s", ErrNoteDeleted, id, g.name)
+	}
+	if n.Id != id {
+		return n, fmt.Errorf("%w: note %v of group %s is stored as note %v", ErrCorrupt, id, g.name, n.Id)
+	}
+	return n, nil
+}
@@ -0,0 +1,375 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// FormatVersion is the version of the binary layout of .kps files written by
+// this package. It is recorded in backups so an archive is never restored into
+// a keep that can't read it.
+//
+// Since version 2 every file starts with formatMagic and its version. Fields
+// are only ever appended to NoteFileHeader and Note, so records written by an
+// older version are read by zero padding them up to the current size. The one
+// exception is the origin of moved notes, which version 10 stores in place of
+// the one of version 9; migrate converts it.
+const FormatVersion = 10
+
+// formatMagic is "KPS\0". Version 1 files start with the first rune of the
+// group title instead, and it is way above any valid rune.
+const formatMagic uint32 = 0x4B505300
+
+type formatPrefix struct {
+	Magic   uint32
+	Version uint32
+}
+
+type layout struct {
+	prefixed bool // whether the file starts with a formatPrefix
+	header   int  // size of the header record
+	note     int  // size of each note record
+}
+
+// layouts holds the record sizes written by each format version.
+var layouts = map[uint32]layout{
+	1:  {prefixed: false, header: 896, note: 1220},
+	2:  {prefixed: true, header: 948, note: 1248},
+	3:  {prefixed: true, header: 948, note: 1264},
+	4:  {prefixed: true, header: 948, note: 1280},
+	5:  {prefixed: true, header: 948, note: 1344},
+	6:  {prefixed: true, header: 948, note: 1352},
+	7:  {prefixed: true, header: 948, note: 1356},
+	8:  {prefixed: true, header: 948, note: 1357},
+	9:  {prefixed: true, header: 948, note: 1445},
+	10: {prefixed: true, header: 948, note: 1532},
+}
+
+// originV9 is how format version 9 recorded the origin of a note, after the
+// fields of version 8.
+type originV9 struct {
+	Group [20]rune
+	Id    int64
+}
+
+// migrate fills in a note decoded from the record of an older format version
+// the fields that can't just be left as zero, and those it stored otherwise.
+func migrate(n *Note, from uint32, record []byte) error {
+	if n.Id <= 0 {
+		return nil
+	}
+	if from < 3 && n.UID.IsZero() {
+		n.UID = NewUID(n.CreatedAt)
+	}
+	if from == 9 {
+		// version 9 kept the origin in a field too short for nested names
+		var origin originV9
+		if err := binary.Read(bytes.NewReader(record[layouts[8].note:]), binary.BigEndian, &origin); err != nil {
+			return err
+		}
+		group := strings.TrimRight(string(origin.Group[:]), "\x00")
+		if err := n.SetOrigin(group, origin.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l layout) headerSize() int64 {
+	if l.prefixed {
+		return int64(binary.Size(formatPrefix{}) + l.header)
+	}
+	return int64(l.header)
+}
+
+// readHeader reads the header of a .kps file of any format version.
+func readHeader(r io.Reader) (NoteFileHeader, uint32, error) {
+	var (
+		nfh   NoteFileHeader
+		first [4]byte
+	)
+
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return nfh, 0, err
+	}
+
+	version := uint32(1)
+	if binary.BigEndian.Uint32(first[:]) == formatMagic {
+		if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+			return nfh, 0, err
+		}
+	}
+
+	l, ok := layouts[version]
+	if !ok {
+		return nfh, version, fmt.Errorf("unsupported format version %v", version)
+	}
+
+	record := make([]byte, l.header)
+	if l.prefixed {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nfh, version, err
+		}
+	} else {
+		copy(record, first[:])
+		if _, err := io.ReadFull(r, record[len(first):]); err != nil {
+			return nfh, version, err
+		}
+	}
+
+	return nfh, version, decodePadded(record, &nfh)
+}
+
+func writeHeader(w io.Writer, nfh *NoteFileHeader) error {
+	prefix := formatPrefix{Magic: formatMagic, Version: FormatVersion}
+	if err := binary.Write(w, binary.BigEndian, &prefix); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, nfh)
+}
+
+// readRecord reads a note record written by the given format version,
+// migrating it to the current one.
+func readRecord(r io.Reader, version uint32) (Note, error) {
+	var n Note
+	record := make([]byte, layouts[version].note)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return n, err
+	}
+	if err := decodePadded(record, &n); err != nil {
+		return n, err
+	}
+	if version == FormatVersion {
+		return n, nil
+	}
+	return n, migrate(&n, version, record)
+}
+
+// decodePadded decodes a record into v, treating the fields missing from an
+// older, shorter record as zero.
+func decodePadded(record []byte, v any) error {
+	if size := binary.Size(v); len(record) < size {
+		padded := make([]byte, size)
+		copy(padded, record)
+		record = padded
+	}
+	return binary.Read(bytes.NewReader(record), binary.BigEndian, v)
+}
+
+// groupFile is the open and locked file of a group.
+type groupFile struct {
+	*os.File
+	name    string
+	header  NoteFileHeader
+	version uint32
+	layout  layout
+	key     []byte // encryption key, once the group is unlocked
+}
+
+// groupFilepath returns the path of the file of a group, failing for names
+// that could point anywhere else.
+func groupFilepath(groupName string) (string, error) {
+	name, err := ParseGroupName(groupName)
+	if err != nil {
+		return "", err
+	}
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(kfp, name.filename()), nil
+}
+
+// openGroup opens the file of a group and reads its header. Groups opened for
+// writing are locked exclusively and upgraded to the current format.
+func openGroup(groupName string, write bool) (*groupFile, error) {
+	if err := recoverJournal(); err != nil {
+		return nil, err
+	}
+
+	noteFilepath, err := groupFilepath(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	flag := os.O_RDONLY
+	if write {
+		flag = os.O_RDWR
+	}
+
+	f, err := openLocked(noteFilepath, flag, 0, write)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open group %s: %w", groupName, err)
+	}
+
+	g := &groupFile{File: f, name: groupName}
+
+	g.header, g.version, err = readHeader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%w: unable to read header of group %s: %w", ErrCorrupt, groupName, err)
+	}
+	g.layout = layouts[g.version]
+
+	// readers upgrade old files too, as notes must keep the same UID across
+	// reads
+	if !write && g.version != FormatVersion {
+		f.Close()
+		return openGroup(groupName, true)
+	}
+
+	if write && g.version != FormatVersion {
+		if err := g.upgrade(); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("unable to upgrade group %s: %w", groupName, err)
+		}
+	}
+
+	return g, nil
+}
+
+// createGroup writes a new group file, failing if the group already exists.
+func createGroup(groupName string, header NoteFileHeader) error {
+	noteFilepath, err := groupFilepath(groupName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(noteFilepath), 0755); err != nil {
+		return err
+	}
+	f, err := openLocked(noteFilepath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600, true)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("group %s already exists", groupName)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeHeader(f, &header)
+}
+
+func (g *groupFile) offset(id int64) int64 {
+	return g.layout.headerSize() + int64(g.layout.note)*(id-1)
+}
+
+// readNote reads the record of the note with the given id as it is stored,
+// i.e. still encrypted for encrypted groups.
+func (g *groupFile) readNote(id int64) (Note, error) {
+	if id < 1 || id > int64(g.header.SizeAlltime) {
+		return Note{}, fmt.Errorf("%w: %v in group %s", ErrNoteNotFound, id, g.name)
+	}
+	if _, err := g.Seek(g.offset(id), io.SeekStart); err != nil {
+		return Note{}, err
+	}
+	n, err := readRecord(g, g.version)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, fmt.Errorf("%w: note %v of group %s is missing", ErrCorrupt, id, g.name)
+	}
+	return n, err
+}
+
+// writeNote writes the record of a note at the position of its id. The file
+// must have been opened for writing.
+func (g *groupFile) writeNote(id int64, n *Note) error {
+	if _, err := g.Seek(g.offset(id), io.SeekStart); err != nil {
+		return err
+	}
+	return binary.Write(g, binary.BigEndian, n)
+}
+
+func (g *groupFile) writeHeader() error {
+	if _, err := g.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return writeHeader(g, &g.header)
+}
+
+// records reads every record of the group, deleted ones included, as they
+// are stored. A truncated record at the end of the file is ignored.
+func (g *groupFile) records() ([]Note, error) {
+	if _, err := g.Seek(g.layout.headerSize(), io.SeekStart); err != nil {
+		return nil, err
+	}
+	var result []Note
+	for {
+		n, err := readRecord(g, g.version)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		result = append(result, n)
+	}
+}
+
+// upgrade rewrites the whole file with the current format.
+func (g *groupFile) upgrade() error {
+	records, err := g.records()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, &g.header); err != nil {
+		return err
+	}
+	for i := range records {
+		if err := binary.Write(&buf, binary.BigEndian, &records[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := g.rewrite(buf.Bytes()); err != nil {
+		return err
+	}
+
+	g.version = FormatVersion
+	g.layout = layouts[FormatVersion]
+	return nil
+}
+
+// rewrite replaces the whole file of the group with data. The file is
+// journaled first, so a keep stopped halfway leaves the group as it was.
+func (g *groupFile) rewrite(data []byte) error {
+	info, err := g.Stat()
+	if err != nil {
+		return err
+	}
+	j, err := beginJournal(map[*groupFile][][2]int64{g: {{0, info.Size()}}})
+	if err != nil {
+		return err
+	}
+
+	err = g.Truncate(0)
+	if err == nil {
+		_, err = g.WriteAt(data, 0)
+	}
+	if err == nil {
+		err = g.Sync()
+	}
+	if err != nil {
+		if rerr := j.rollback(g); rerr != nil {
+			return fmt.Errorf("%w, and rolling back failed: %v", err, rerr)
+		}
+		return err
+	}
+	return j.commit()
+}
+
+// groupNameOf returns the group name of a .kps file name relative to the keep
+// folder.
+func groupNameOf(filename string) string {
+	return strings.TrimSuffix(filename, ".kps")
+}
@@ -0,0 +1,81 @@
+package notes
+
+import (
+	"strings"
+
+	"github.com/DavidEsdrs/keep/groupname"
+)
+
+// GroupName is the name of a group, which is also the name of its file within
+// the keep folder. Groups nest: the name of a subgroup is the one of its parent
+// followed by a slash and its own, as in "work/projectA/meetings", and its
+// file is in the directory named after its parent.
+type GroupName string
+
+// DefaultGroup is the group of the notes created without group.
+const DefaultGroup = GroupName(groupname.Default)
+
+// MaxGroupNameLength is the length of the title in the group header, which
+// holds the last part of the name.
+const MaxGroupNameLength = groupname.MaxLength
+
+// MaxGroupDepth is how deep groups can nest.
+const MaxGroupDepth = groupname.MaxDepth
+
+// MaxGroupPathLength is the length of the longest valid group name.
+const MaxGroupPathLength = groupname.MaxPathLength
+
+// ParseGroupName validates the name of an existing group, as by
+// groupname.Parse.
+func ParseGroupName(s string) (GroupName, error) {
+	if err := groupname.Parse(s); err != nil {
+		return "", err
+	}
+	return GroupName(s), nil
+}
+
+// NewGroupName validates the name of a group about to be created or renamed,
+// as by groupname.Check.
+func NewGroupName(s string) (GroupName, error) {
+	if err := groupname.Check(s); err != nil {
+		return "", err
+	}
+	return GroupName(s), nil
+}
+
+// Parent returns the name of the group n is a subgroup of, if any.
+func (n GroupName) Parent() (GroupName, bool) {
+	i := strings.LastIndex(string(n), "/")
+	if i < 0 {
+		return "", false
+	}
+	return n[:i], true
+}
+
+// Base returns the last part of the name.
+func (n GroupName) Base() string {
+	return string(n[strings.LastIndex(string(n), "/")+1:])
+}
+
+// Contains reports whether other is n or one of its subgroups, however deep.
+func (n GroupName) Contains(other GroupName) bool {
+	return other == n || strings.HasPrefix(string(other), string(n)+"/")
+}
+
+// title returns the last part of the name, as stored in the group header.
+func (n GroupName) title() [20]rune {
+	var title [20]rune
+	copy(title[:], []rune(n.Base()))
+	return title
+}
+
+// dirname returns the directory holding the files of the subgroups of n
+// within the keep folder.
+func (n GroupName) dirname() string {
+	return string(n)
+}
+
+// filename returns the name of the file of the group within the keep folder.
+func (n GroupName) filename() string {
+	return string(n) + ".kps"
+}
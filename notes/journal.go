@@ -0,0 +1,250 @@
+package notes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// journalFile is where an operation spanning many group files records what it
+// is about to overwrite, so it can be undone if it doesn't complete.
+const journalFile = ".journal"
+
+// journal is an undo log of the changes to some group files. It is written
+// before any of them changes and removed once all of them did, so a journal
+// left behind means a change stopped halfway and is rolled back.
+//
+// The journal stays locked while in use. Whoever finds it empty once locked
+// finds a journal committed while it waited for the lock.
+type journal struct {
+	f       *os.File
+	Files   []journaledFile
+	Renamed []journaledRename
+}
+
+type journaledFile struct {
+	Name    string // file name relative to the keep folder
+	Size    int64  // size of the file before the change
+	Regions []journaledRegion
+}
+
+// journaledRegion holds the content of a file at some offset before the
+// change.
+type journaledRegion struct {
+	Offset int64
+	Data   []byte
+}
+
+// journaledRename is a file given another name by the change. Files are
+// renamed by linking the new name first, so both may exist at once.
+type journaledRename struct {
+	From, To string // file names relative to the keep folder
+}
+
+func journalPath() (string, error) {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(kfp, journalFile), nil
+}
+
+// beginJournal records the given regions of the groups, as [offset, length]
+// pairs, before they are written, along with the renames the change makes. The
+// groups must be open for writing.
+func beginJournal(regions map[*groupFile][][2]int64, renames ...journaledRename) (*journal, error) {
+	name, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("another change is in progress, try again")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, true); err != nil {
+		f.Close()
+		os.Remove(name)
+		return nil, err
+	}
+
+	j := &journal{f: f, Renamed: renames}
+	for g, rs := range regions {
+		info, err := g.Stat()
+		if err != nil {
+			j.discard()
+			return nil, err
+		}
+		jf := journaledFile{Name: GroupName(g.name).filename(), Size: info.Size()}
+		for _, r := range rs {
+			data := make([]byte, r[1])
+			n, err := g.ReadAt(data, r[0])
+			if err != nil && !errors.Is(err, io.EOF) {
+				j.discard()
+				return nil, err
+			}
+			jf.Regions = append(jf.Regions, journaledRegion{Offset: r[0], Data: data[:n]})
+		}
+		j.Files = append(j.Files, jf)
+	}
+	sort.Slice(j.Files, func(a, b int) bool { return j.Files[a].Name < j.Files[b].Name })
+
+	if err := json.NewEncoder(f).Encode(j); err != nil {
+		j.discard()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		j.discard()
+		return nil, err
+	}
+	return j, nil
+}
+
+// commit marks the change as complete, once the groups were synced.
+func (j *journal) commit() error {
+	defer j.f.Close()
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	return os.Remove(j.f.Name())
+}
+
+// discard removes a journal nothing was changed under yet.
+func (j *journal) discard() {
+	j.f.Truncate(0)
+	os.Remove(j.f.Name())
+	j.f.Close()
+}
+
+// rollback undoes the change on the given groups, which are still open, and
+// removes the journal.
+func (j *journal) rollback(groups ...*groupFile) error {
+	files := make(map[string]*os.File, len(groups))
+	for _, g := range groups {
+		files[GroupName(g.name).filename()] = g.File
+	}
+	if err := j.undoRenames(); err != nil {
+		j.f.Close()
+		return err
+	}
+	if err := j.restore(files); err != nil {
+		j.f.Close()
+		return err
+	}
+	return j.commit()
+}
+
+// undoRenames gives the renamed files their names back.
+func (j *journal) undoRenames() error {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return err
+	}
+	for _, r := range j.Renamed {
+		from, to := path.Join(kfp, r.From), path.Join(kfp, r.To)
+		fromInfo, fromErr := os.Stat(from)
+		toInfo, toErr := os.Stat(to)
+		switch {
+		case errors.Is(fromErr, os.ErrNotExist) && toErr == nil:
+			if err := os.Rename(to, from); err != nil {
+				return err
+			}
+		case fromErr == nil && toErr == nil && os.SameFile(fromInfo, toInfo):
+			if err := os.Remove(to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (j *journal) restore(files map[string]*os.File) error {
+	for _, jf := range j.Files {
+		f, ok := files[jf.Name]
+		if !ok {
+			return fmt.Errorf("file %s of the journal isn't open", jf.Name)
+		}
+		for _, r := range jf.Regions {
+			if _, err := f.WriteAt(r.Data, r.Offset); err != nil {
+				return err
+			}
+		}
+		if err := f.Truncate(jf.Size); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverJournal rolls back the change of a journal left behind by a keep that
+// stopped halfway through it. It waits for changes still in progress.
+func recoverJournal() error {
+	name, err := journalPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f, true); err != nil {
+		f.Close()
+		return err
+	}
+	j := &journal{f: f}
+
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		// committed while waiting for the lock
+		f.Close()
+		return err
+	}
+	if err := json.NewDecoder(f).Decode(j); err != nil {
+		// stopped while writing the journal, before anything changed
+		j.discard()
+		return nil
+	}
+
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := j.undoRenames(); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to roll back an unfinished change: %w", err)
+	}
+	files := make(map[string]*os.File, len(j.Files))
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, jf := range j.Files {
+		gf, err := openLocked(path.Join(kfp, jf.Name), os.O_RDWR, 0, true)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("unable to roll back an unfinished change: %w", err)
+		}
+		files[jf.Name] = gf
+	}
+	if err := j.restore(files); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to roll back an unfinished change: %w", err)
+	}
+	return j.commit()
+}
@@ -0,0 +1,169 @@
+package notes
+
+import (
+	"os"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func TestRecoverJournal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"inbox", "work"} {
+		if _, err := NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddNote(name, "note of "+name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src, err := openGroup("inbox", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := openGroup("work", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note, err := src.readNote(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note.Id = 2
+	j, err := beginJournal(map[*groupFile][][2]int64{
+		src: {{0, src.layout.headerSize()}, {src.offset(1), int64(src.layout.note)}},
+		dst: {{0, dst.layout.headerSize()}, {dst.offset(2), int64(dst.layout.note)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stop after writing the note into dst but before deleting it from src
+	dst.header.Size++
+	dst.header.SizeAlltime++
+	if err := dst.writeNote(2, &note); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.writeHeader(); err != nil {
+		t.Fatal(err)
+	}
+	j.f.Close()
+	src.Close()
+	dst.Close()
+
+	for _, name := range []string{"inbox", "work"} {
+		header, groupNotes, err := ReadGroup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Size != 1 || header.SizeAlltime != 1 || len(groupNotes) != 1 || groupNotes[0].String() != "note of "+name {
+			t.Fatalf("group %s not rolled back: %+v %v", name, header.Size, groupNotes)
+		}
+	}
+	if _, err := os.Stat(j.f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("journal left behind: %v", err)
+	}
+}
+
+func TestRecoverRename(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNoteFile("bokos", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := openGroup("bokos", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := beginJournal(
+		map[*groupFile][][2]int64{g: {{0, g.layout.headerSize()}}},
+		journaledRename{From: "bokos.kps", To: "books.kps"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stop after the whole rename but before committing it
+	oldFilepath, _ := groupFilepath("bokos")
+	newFilepath, _ := groupFilepath("books")
+	if err := os.Link(oldFilepath, newFilepath); err != nil {
+		t.Fatal(err)
+	}
+	g.header.Title = [20]rune{'b', 'o', 'o', 'k', 's'}
+	if err := renameGroup(g, oldFilepath, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	j.f.Close()
+	g.Close()
+
+	header, err := GetGroupHeader("bokos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Title != [20]rune{'b', 'o', 'k', 'o', 's'} {
+		t.Fatalf("title not rolled back: %q", string(header.Title[:]))
+	}
+	if names, err := GroupNames(); err != nil || len(names) != 1 || names[0] != "bokos" {
+		t.Fatalf("rename not rolled back: %v %v", names, err)
+	}
+}
+
+func TestRecoverRewrite(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNoteFile("inbox", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddNote("inbox", "Buy milk"); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := openGroup("inbox", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := g.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := beginJournal(map[*groupFile][][2]int64{g: {{0, info.Size()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stop after emptying the file as a rewrite does, before writing it again
+	if err := g.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	j.f.Close()
+	g.Close()
+
+	header, groupNotes, err := ReadGroup("inbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Size != 1 || len(groupNotes) != 1 || groupNotes[0].String() != "Buy milk" {
+		t.Fatalf("group not rolled back: %+v %v", header.Size, groupNotes)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package notes
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile places an advisory lock on f, blocking until it is acquired. Shared
+// locks may be held by many readers at once, exclusive locks by one writer.
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package notes
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile places a lock over the whole file, blocking until it is acquired.
+// Shared locks may be held by many readers at once, exclusive locks by one
+// writer.
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, ^uint32(0), ^uint32(0), ol)
+}
+
+func unlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, ^uint32(0), ^uint32(0), ol)
+}
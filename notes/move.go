@@ -0,0 +1,131 @@
+package notes
+
+import (
+	"fmt"
+	"time"
+)
+
+// MoveOptions tells how to move or copy a note to another group.
+type MoveOptions struct {
+	Copy bool // leave the note in its group too
+	// Ref records on the new note the group and id it came from.
+	Ref bool
+}
+
+// MoveNote moves a note to another group, where it gets the next id, and
+// returns it. Moved notes keep their UID, copies get a new one.
+//
+// Both groups change at once: if keep stops halfway, the change is rolled back
+// the next time a group is opened. Moving notes of or into encrypted groups
+// asks for their passphrases.
+func MoveNote(from string, id int64, to string, opts MoveOptions) (Note, error) {
+	if from == to {
+		return Note{}, fmt.Errorf("note %v is already in group %s", id, to)
+	}
+
+	if !opts.Copy && BeforeDestroy != nil {
+		if err := checkLiveNote(from, id); err != nil {
+			return Note{}, err
+		}
+		if err := BeforeDestroy(from); err != nil {
+			return Note{}, fmt.Errorf("note not moved: %w", err)
+		}
+	}
+
+	// always lock in the order of file names, as LockStore does, so two
+	// callers can't deadlock each other
+	first, second := from, to
+	if GroupName(second).filename() < GroupName(first).filename() {
+		first, second = second, first
+	}
+	a, err := openGroup(first, true)
+	if err != nil {
+		return Note{}, err
+	}
+	defer a.Close()
+	b, err := openGroup(second, true)
+	if err != nil {
+		return Note{}, err
+	}
+	defer b.Close()
+	src, dst := a, b
+	if src.name != from {
+		src, dst = b, a
+	}
+
+	if err := dst.holdsNotes(); err != nil {
+		return Note{}, err
+	}
+
+	note, err := src.readLiveNote(id)
+	if err != nil {
+		return note, err
+	}
+
+	if err := src.open(&note); err != nil {
+		return note, err
+	}
+	note.Id = int64(dst.header.SizeAlltime) + 1
+	if opts.Copy {
+		note.UID = NewUID(time.Now().UnixMilli())
+	}
+	origin := ""
+	if opts.Ref {
+		origin = string(from)
+	}
+	if err := note.SetOrigin(origin, id); err != nil {
+		return note, err
+	}
+	moved := note
+
+	if err := dst.seal(&note); err != nil {
+		return moved, err
+	}
+
+	regions := map[*groupFile][][2]int64{
+		dst: {{0, dst.layout.headerSize()}, {dst.offset(note.Id), int64(dst.layout.note)}},
+	}
+	if !opts.Copy {
+		regions[src] = [][2]int64{{0, src.layout.headerSize()}, {src.offset(id), int64(src.layout.note)}}
+	}
+	j, err := beginJournal(regions)
+	if err != nil {
+		return moved, err
+	}
+
+	if err := moveNote(src, id, dst, &note, opts.Copy); err != nil {
+		if rerr := j.rollback(src, dst); rerr != nil {
+			return moved, fmt.Errorf("%w, and rolling back failed: %v", err, rerr)
+		}
+		return moved, err
+	}
+	return moved, j.commit()
+}
+
+// moveNote writes the note into dst and, unless copying, deletes the one of
+// src it came from.
+func moveNote(src *groupFile, id int64, dst *groupFile, note *Note, copying bool) error {
+	if err := dst.writeNote(note.Id, note); err != nil {
+		return err
+	}
+	dst.header.Size++
+	dst.header.SizeAlltime++
+	if err := dst.writeHeader(); err != nil {
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+	if copying {
+		return nil
+	}
+
+	if err := src.writeNote(id, &Note{Id: -1}); err != nil {
+		return err
+	}
+	src.header.Size--
+	if err := src.writeHeader(); err != nil {
+		return err
+	}
+	return src.Sync()
+}
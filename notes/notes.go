@@ -1,17 +1,19 @@
 package notes
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/DavidEsdrs/keep/common"
 	"github.com/DavidEsdrs/keep/configs"
+	"github.com/DavidEsdrs/keep/recur"
+	"github.com/DavidEsdrs/keep/style"
 	"github.com/DavidEsdrs/keep/utils"
 	"github.com/fatih/color"
 )
@@ -22,35 +24,62 @@ type NoteFileHeader struct {
 	Size        uint32
 	SizeAlltime uint32
 	CreatedAt   int64 // timestamp
+	Flags       uint32
+	Salt        [16]byte // salt of the key of encrypted groups
+	KeyCheck    [32]byte // see keyCheck
 }
 
-func (n *NoteFileHeader) Show() {
-	c := color.New(color.FgHiWhite).Add(color.Bold)
-
-	t := time.Unix(n.CreatedAt/1000, 0)
-
-	blue := color.New(color.BgHiBlue).Add(color.Bold)
+// TimeLayout and DateLayout are the layouts of the time groups and notes were
+// created at, the first for the ones created today.
+var (
+	TimeLayout = time.Kitchen
+	DateLayout = "01/02/2006"
+)
 
-	blue.DisableColor()
+func (n *NoteFileHeader) Show() {
+	n.Render(color.Output, strings.TrimRight(string(n.Title[:]), "\x00"), "", time.Now())
+}
 
-	blue.Print(string(n.Title[:]) + " ~ ")
+// ShowWithProgress shows the header of the group of the given name, which for
+// subgroups is longer than their title, along with how many of its notes are
+// done.
+func (n *NoteFileHeader) ShowWithProgress(name string, done, total int) {
+	n.Render(color.Output, name, fmt.Sprintf("%v/%v done", done, total), time.Now())
+}
 
-	blue.EnableColor()
+// ShowVirtual shows the header of the smart group of the given name, its query
+// in place of its description.
+func (n *NoteFileHeader) ShowVirtual(name string) {
+	n.Render(color.Output, name, "virtual", time.Now())
+}
 
-	now := time.Now()
+// Render writes the header of the group of the given name, followed by detail
+// when given, in the colors of the theme in use. The time the group was
+// created at is shown in the location of now, as a time when it is the same
+// day as now.
+func (n *NoteFileHeader) Render(w io.Writer, name, detail string, now time.Time) {
+	theme := style.Current
+	if n.Encrypted() {
+		fmt.Fprint(w, "🔒 ")
+	}
+	fmt.Fprintf(w, "%-*s ~ ", MaxGroupNameLength, name)
+	fmt.Fprint(w, theme.Badge.Sprintf(" %v ", formatCreatedAt(n.CreatedAt, now)))
+	fmt.Fprint(w, " - ")
+	fmt.Fprint(w, theme.Heading.Sprintf(" %s ", strings.TrimRight(string(n.Description[:]), "\x00")))
+	if detail != "" {
+		fmt.Fprint(w, theme.Faint.Sprintf(" %s", detail))
+	}
+	fmt.Fprintln(w)
+}
 
-	if t.Year() == now.Year() && t.Month() == now.Month() && t.Day() == now.Day() {
-		blue.Printf(" %v ", t.Local().Format(time.Kitchen))
-	} else {
-		blue.Printf(" %v ", t.Local().Format("01/02/2006"))
+// formatCreatedAt formats the timestamp something was created at with
+// TimeLayout when it is the same day as now, with DateLayout otherwise.
+func formatCreatedAt(createdAt int64, now time.Time) string {
+	t := time.UnixMilli(createdAt).In(now.Location())
+	if t.Year() == now.Year() && t.YearDay() == now.YearDay() {
+		return t.Format(TimeLayout)
 	}
-
-	blue.DisableColor()
-	blue.Print(" - ")
-	blue.EnableColor()
-
-	c.Printf(" %s ", string(n.Description[:]))
-	c.Println()
+	return t.Format(DateLayout)
 }
 
 func NewNoteFileHeader(t, d string, size, sizeAllTime uint32) NoteFileHeader {
@@ -72,10 +101,21 @@ func NewNoteFileHeader(t, d string, size, sizeAllTime uint32) NoteFileHeader {
 }
 
 type Note struct {
-	Id        int64
-	Text      [300]rune
-	Color     int32
-	CreatedAt int64
+	Id           int64
+	Text         [300]rune
+	Color        int32
+	CreatedAt    int64
+	Nonce        [12]byte // nonce and tag of the text of encrypted notes
+	Tag          [16]byte
+	UID          UID
+	Due          int64    // timestamp, 0 for notes without due date
+	RemindBefore int64    // milliseconds before Due to remind of the note
+	Repeat       [64]byte // recurrence rule of repeating notes, see package recur
+	DoneAt       int64    // timestamp the note was marked done at, 0 while open
+	Priority     Priority
+	Pinned       bool                     // pinned notes are listed first
+	MovedFromId  int64                    // id the note had in the group it was moved or copied from
+	MovedFrom    [MaxGroupPathLength]byte // name of that group in UTF-8, if recorded
 }
 
 func NewNote(id int64, text string, c color.Attribute, createAt int64) Note {
@@ -86,238 +126,606 @@ func NewNote(id int64, text string, c color.Attribute, createAt int64) Note {
 		Text:      t,
 		Color:     int32(c),
 		CreatedAt: createAt,
+		UID:       NewUID(createAt),
 	}
 }
 
-func (n Note) Show() {
-	c := color.New(color.Attribute(n.Color)).Add(color.Bold)
+// NoteOptions holds what can be set on a new note besides its text.
+type NoteOptions struct {
+	Due          time.Time // zero for notes without due date
+	RemindBefore time.Duration
+	// Repeat makes the note repeat. Repeating notes without due date are
+	// first due at the next occurrence of the rule.
+	Repeat   recur.Rule
+	Priority Priority
+	// Color of the note, 0 for the color of its priority.
+	Color color.Attribute
+}
 
-	t := time.Unix(n.CreatedAt/1000, 0)
+func (o NoteOptions) apply(n *Note) error {
+	if o.RemindBefore != 0 && o.Due.IsZero() && o.Repeat == nil {
+		return fmt.Errorf("a reminder needs a due date")
+	}
+	if !o.Due.IsZero() {
+		n.Due = o.Due.UnixMilli()
+	}
+	n.RemindBefore = o.RemindBefore.Milliseconds()
+	n.Priority = o.Priority
+	n.Color = int32(o.Color)
 
-	blue := color.New(color.BgHiBlue).Add(color.Bold)
+	if o.Repeat != nil {
+		rule := o.Repeat.String()
+		if len(rule) > len(n.Repeat) {
+			return fmt.Errorf("recurrence rule %q is too long", rule)
+		}
+		copy(n.Repeat[:], rule)
+		if n.Due == 0 {
+			first := o.Repeat.Next(time.UnixMilli(n.CreatedAt))
+			if first.IsZero() {
+				return fmt.Errorf("recurrence rule %q never occurs", rule)
+			}
+			n.Due = first.UnixMilli()
+		}
+	}
+	return nil
+}
 
-	blue.DisableColor()
+// Done reports whether the note was marked done.
+func (n Note) Done() bool {
+	return n.DoneAt != 0
+}
 
-	blue.Print(fmt.Sprint(n.Id) + " ~ ")
+// Repeats reports whether the note has a recurrence rule.
+func (n Note) Repeats() bool {
+	return n.Repeat[0] != 0
+}
 
-	blue.EnableColor()
+// RepeatRule returns the recurrence rule of the note, or nil if it doesn't
+// repeat.
+func (n Note) RepeatRule() (recur.Rule, error) {
+	if !n.Repeats() {
+		return nil, nil
+	}
+	return recur.Parse(strings.TrimRight(string(n.Repeat[:]), "\x00"))
+}
 
-	now := time.Now()
+// Origin returns the group and id the note was moved or copied from, or an
+// empty group if that wasn't recorded.
+func (n Note) Origin() (string, int64) {
+	return strings.TrimRight(string(n.MovedFrom[:]), "\x00"), n.MovedFromId
+}
 
-	if t.Year() == now.Year() && t.Month() == now.Month() && t.Day() == now.Day() {
-		blue.Printf(" %v ", t.Local().Format(time.Kitchen))
+// SetOrigin records the group and id the note was moved or copied from. An
+// empty group clears them. Names are stored in UTF-8, so the longest names
+// fit unless they hold characters other than ASCII.
+func (n *Note) SetOrigin(group string, id int64) error {
+	if len(group) > len(n.MovedFrom) {
+		return fmt.Errorf("group name %q is too long to be recorded", group)
+	}
+	n.MovedFrom = [MaxGroupPathLength]byte{}
+	copy(n.MovedFrom[:], group)
+	n.MovedFromId = id
+	if group == "" {
+		n.MovedFromId = 0
+	}
+	return nil
+}
+
+// DueTime returns when the note is due, or the zero time if it isn't.
+func (n Note) DueTime() time.Time {
+	if n.Due == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(n.Due)
+}
+
+// RemindTime returns when to remind of the note, i.e. RemindBefore ahead of
+// its due date, or the zero time if it isn't due.
+func (n Note) RemindTime() time.Time {
+	if n.Due == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(n.Due - n.RemindBefore)
+}
+
+// String returns the text of the note.
+func (n Note) String() string {
+	return strings.TrimRight(string(n.Text[:]), "\x00")
+}
+
+func (n Note) Show() {
+	n.Render(color.Output, time.Now())
+}
+
+// Render writes the note in its color, after its id and the time it was
+// created at in the colors of the theme in use. Times are shown in the
+// location of now, and due dates before now as gone by.
+func (n Note) Render(w io.Writer, now time.Time) {
+	theme := style.Current
+	c := style.Style{n.DisplayColor(), color.Bold}
+
+	fmt.Fprintf(w, "%v ~ ", n.Id)
+	fmt.Fprint(w, theme.Badge.Sprintf(" %v ", formatCreatedAt(n.CreatedAt, now)))
+	fmt.Fprint(w, " - ")
+
+	text := n.String()
+	if n.Done() {
+		text = "[x] " + text
 	} else {
-		blue.Printf(" %v ", t.Local().Format("01/02/2006"))
+		text = "[ ] " + text
+	}
+	if n.Pinned {
+		text = "📌 " + text
 	}
+	fmt.Fprint(w, c.Sprint(text))
 
-	blue.DisableColor()
-	blue.Print(" - ")
-	blue.EnableColor()
-	c.Print(string(n.Text[:]))
-	c.Println()
+	if due := n.DueTime(); !due.IsZero() {
+		dueColor := theme.Faint
+		if due.Before(now) {
+			dueColor = theme.Alert
+		}
+		fmt.Fprint(w, dueColor.Sprintf(" (due %s)", due.In(now.Location()).Format("Mon Jan 2 15:04")))
+	}
+	if rule, err := n.RepeatRule(); err == nil && rule != nil {
+		fmt.Fprint(w, theme.Faint.Sprintf(" (repeats %s)", rule))
+	}
+	if group, id := n.Origin(); group != "" {
+		fmt.Fprint(w, theme.Faint.Sprintf(" (from %s %v)", group, id))
+	}
+	fmt.Fprintln(w)
 }
 
-// creates a new file named [title].kps with starting values
+// creates a new file named [title].kps with starting values. Subgroups can
+// only be created within existing groups.
 func NewNoteFile(title, description string) (NoteFileHeader, error) {
-	var nfh NoteFileHeader
-	kfp, err := utils.GetKeepFilePath()
+	name, err := newGroup(title)
 	if err != nil {
-		return nfh, err
+		return NoteFileHeader{}, err
 	}
-	noteFilepath := path.Join(kfp, title+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_CREATE|os.O_RDWR, 0600)
+	header := NewNoteFileHeader(name.Base(), description, 0, 0)
+	return header, createGroup(title, header)
+}
+
+// NewEncryptedNoteFile creates a group whose notes are encrypted with a key
+// derived from the given passphrase.
+func NewEncryptedNoteFile(title, description, passphrase string) (NoteFileHeader, error) {
+	name, err := newGroup(title)
 	if err != nil {
-		return nfh, err
+		return NoteFileHeader{}, err
 	}
-	defer f.Close()
-	header := NewNoteFileHeader(title, description, 0, 0)
-	err = binary.Write(f, binary.BigEndian, &header)
-	return nfh, err
+	header := NewNoteFileHeader(name.Base(), description, 0, 0)
+	if err := header.encrypt(passphrase); err != nil {
+		return header, err
+	}
+	return header, createGroup(title, header)
+}
+
+// newGroup validates the name of a new group, whose parent must exist.
+func newGroup(s string) (GroupName, error) {
+	name, err := NewGroupName(s)
+	if err != nil {
+		return name, err
+	}
+	if parent, ok := name.Parent(); ok {
+		parentFilepath, err := groupFilepath(string(parent))
+		if err != nil {
+			return name, err
+		}
+		if !utils.DoesFileExists(parentFilepath) {
+			return name, fmt.Errorf("group %s doesn't exist, create it before its subgroups", parent)
+		}
+	}
+	return name, nil
 }
 
 func AddNote(groupname string, text string) error {
-	var nfh NoteFileHeader
+	_, err := appendNote(groupname, text, NoteOptions{})
+	return err
+}
 
-	kfp, err := utils.GetKeepFilePath()
+// AddNoteWith adds a note with the given options to the group and returns it.
+func AddNoteWith(groupName string, text string, opts NoteOptions) (Note, error) {
+	return appendNote(groupName, text, opts)
+}
+
+func appendNote(groupName string, text string, opts NoteOptions) (Note, error) {
+	g, err := openGroup(groupName, true)
 	if err != nil {
-		return err
+		return Note{}, err
+	}
+	defer g.Close()
+
+	if err := g.holdsNotes(); err != nil {
+		return Note{}, err
+	}
+
+	id := int64(g.header.SizeAlltime) + 1
+	note := NewNote(id, text, 0, time.Now().UnixMilli())
+	if err := opts.apply(&note); err != nil {
+		return note, err
 	}
+	added := note
+
+	if err := g.seal(&note); err != nil {
+		return added, err
+	}
+	if err := g.writeNote(id, &note); err != nil {
+		return added, err
+	}
+
+	g.header.Size++
+	g.header.SizeAlltime++
 
-	noteFilepath := path.Join(kfp, groupname+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_RDWR, 0)
+	return added, g.writeHeader()
+}
+
+func GetGroupHeader(groupName string) (NoteFileHeader, error) {
+	g, err := openGroup(groupName, false)
 	if err != nil {
-		return fmt.Errorf("\"%s\" group not found", groupname)
+		return NoteFileHeader{}, err
 	}
-	defer f.Close()
+	defer g.Close()
+	return g.header, nil
+}
 
-	err = binary.Read(f, binary.BigEndian, &nfh)
+// ReadAllNotes emits all notes of a group. They are all read and decrypted
+// before it returns, so a note that can't be fails the whole read rather than
+// going missing.
+func ReadAllNotes(groupName string) (<-chan Note, error) {
+	g, err := openGroup(groupName, false)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer g.Close()
+	if err := g.unlock(); err != nil {
+		return nil, err
 	}
 
-	_, err = f.Seek(0, io.SeekEnd)
+	records, err := g.records()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var live []Note
+	for _, n := range records {
+		if n.Id <= 0 {
+			continue
+		}
+		if err := g.open(&n); err != nil {
+			return nil, err
+		}
+		live = append(live, n)
 	}
 
-	note := NewNote(int64(nfh.SizeAlltime)+1, text, utils.RandomColor(), time.Now().UnixMilli())
+	out := make(chan Note, len(live))
+	for _, n := range live {
+		out <- n
+	}
+	close(out)
+	return out, nil
+}
 
-	err = binary.Write(f, binary.BigEndian, &note)
+func GetNoteById(groupName string, id int64) (Note, error) {
+	g, err := openGroup(groupName, false)
 	if err != nil {
-		return err
+		return Note{}, err
 	}
+	defer g.Close()
 
-	nfh.Size++
-	nfh.SizeAlltime++
-
-	_, err = f.Seek(0, io.SeekStart)
+	result, err := g.readLiveNote(id)
 	if err != nil {
-		return err
+		return result, err
 	}
+	return result, g.open(&result)
+}
 
-	err = binary.Write(f, binary.BigEndian, &nfh)
-	return err
+// GetNote returns the note of the group referred to by ref, which is either
+// its id or its UID.
+func GetNote(groupName string, ref string) (Note, error) {
+	id, err := ResolveNoteRef(groupName, ref)
+	if err != nil {
+		return Note{}, err
+	}
+	return GetNoteById(groupName, id)
 }
 
-func GetGroupHeader(groupName string) (NoteFileHeader, error) {
-	var nfh NoteFileHeader
-	kfp, err := utils.GetKeepFilePath()
+// ResolveNoteRef returns the id of the note of the group referred to by ref,
+// which is either its id or its UID.
+func ResolveNoteRef(groupName string, ref string) (int64, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return id, nil
+	}
+
+	uid, err := ParseUID(ref)
 	if err != nil {
-		return nfh, err
+		return 0, fmt.Errorf("%w: %q is neither a note id nor a uid", ErrInvalidNoteRef, ref)
 	}
-	noteFilepath := path.Join(kfp, groupName+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_RDWR, 0)
+
+	g, err := openGroup(groupName, false)
 	if err != nil {
-		return nfh, err
+		return 0, err
 	}
-	defer f.Close()
-	err = binary.Read(f, binary.BigEndian, &nfh)
-	return nfh, err
+	defer g.Close()
+
+	records, err := g.records()
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range records {
+		if n.Id > 0 && n.UID == uid {
+			return n.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no note with uid %s in group %s", ErrNoteNotFound, uid, groupName)
 }
 
-// ReadAllNotes emits all notes stored within a .kps file
-func ReadAllNotes(filename string) (<-chan Note, error) {
-	var (
-		nfh NoteFileHeader
-		out = make(chan Note)
-	)
-	kfp, err := utils.GetKeepFilePath()
+// EditNote replaces the text of a note, keeping everything else about it.
+func EditNote(groupName string, id int64, text string) (Note, error) {
+	g, err := openGroup(groupName, true)
 	if err != nil {
-		return nil, err
+		return Note{}, err
 	}
-	noteFilepath := path.Join(kfp, filename)
-	f, err := os.OpenFile(noteFilepath, os.O_RDONLY, 0)
+	defer g.Close()
+
+	note, err := g.readLiveNote(id)
 	if err != nil {
-		return nil, fmt.Errorf("no group with given name")
+		return note, err
+	}
+
+	note.Text = [300]rune{}
+	copy(note.Text[:], []rune(text))
+	note.Nonce, note.Tag = [12]byte{}, [16]byte{}
+	edited := note
+
+	if err := g.seal(&note); err != nil {
+		return edited, err
 	}
-	_, err = f.Seek(int64(binary.Size(nfh)), io.SeekStart)
+	return edited, g.writeNote(id, &note)
+}
+
+// BeforeDestroy, when set, is called with the group name before a note or a
+// whole group is deleted. If it fails, nothing is deleted.
+var BeforeDestroy func(groupName string) error
+
+func DeleteNoteById(groupName string, id int64) error {
+	noteFilepath, err := groupFilepath(groupName)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	go func() {
-		var n Note
-		for {
-			err = binary.Read(f, binary.BigEndian, &n)
-			if err != nil {
-				break
-			}
-			if n.Id > 0 {
-				out <- n
-			}
+
+	if !utils.DoesFileExists(noteFilepath) {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+
+	if BeforeDestroy != nil {
+		if err := checkLiveNote(groupName, id); err != nil {
+			return err
+		}
+		if err := BeforeDestroy(groupName); err != nil {
+			return fmt.Errorf("note not deleted: %w", err)
 		}
-		f.Close()
-		close(out)
-	}()
-	return out, err
+	}
+
+	g, err := openGroup(groupName, true)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	if _, err := g.readLiveNote(id); err != nil {
+		return err
+	}
+
+	if err := g.writeNote(id, &Note{Id: -1}); err != nil {
+		return err
+	}
+
+	g.header.Size--
+
+	return g.writeHeader()
 }
 
-func GetNoteById(groupName string, id int64) (Note, error) {
-	var (
-		result Note
-		nfh    NoteFileHeader
-	)
-	kfp, err := utils.GetKeepFilePath()
+// checkLiveNote returns the error reading the note would, so BeforeDestroy
+// isn't run for a note that can't be destroyed anyway.
+func checkLiveNote(groupName string, id int64) error {
+	g, err := openGroup(groupName, false)
 	if err != nil {
-		return result, err
+		return err
 	}
+	defer g.Close()
+	_, err = g.readLiveNote(id)
+	return err
+}
+
+// ErrHasSubgroups is returned when deleting a group with subgroups without
+// deleting them too.
+var ErrHasSubgroups = errors.New("group has subgroups")
 
-	noteFilepath := path.Join(kfp, groupName+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_RDONLY, 0)
+// DeleteGroup deletes a group without subgroups.
+func DeleteGroup(groupName string) error {
+	subgroups, err := Subgroups(groupName)
 	if err != nil {
-		return result, err
+		return err
 	}
-	defer f.Close()
+	if len(subgroups) > 0 {
+		return fmt.Errorf("%w: %s", ErrHasSubgroups, strings.Join(subgroups, ", "))
+	}
+	return deleteGroup(groupName)
+}
 
-	_, err = f.Seek(int64(binary.Size(nfh))+int64(binary.Size(result))*(id-1), io.SeekStart)
+// DeleteGroupTree deletes a group along with its subgroups, however deep.
+func DeleteGroupTree(groupName string) error {
+	if _, err := groupFilepath(groupName); err != nil {
+		return err
+	}
+	subgroups, err := Subgroups(groupName)
 	if err != nil {
-		if errors.Is(err, io.EOF) {
-			return result, fmt.Errorf("invalid id")
+		return err
+	}
+	// subgroups first, so a group is never left without its parent
+	for i := len(subgroups) - 1; i >= 0; i-- {
+		if err := deleteGroup(subgroups[i]); err != nil {
+			return err
 		}
-		return result, fmt.Errorf("invalid id %v for range: %w", id, err)
 	}
+	return deleteGroup(groupName)
+}
 
-	err = binary.Read(f, binary.BigEndian, &result)
+func deleteGroup(groupName string) error {
+	noteFilepath, err := groupFilepath(groupName)
 	if err != nil {
-		if errors.Is(err, io.EOF) {
-			return result, fmt.Errorf("invalid id")
+		return err
+	}
+
+	if !utils.DoesFileExists(noteFilepath) {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+
+	if BeforeDestroy != nil {
+		if err := BeforeDestroy(groupName); err != nil {
+			return fmt.Errorf("group not deleted: %w", err)
 		}
-		return result, err
 	}
 
-	if result.Id != id {
-		return result, fmt.Errorf("unexpected entity got from given id")
+	// wait for anyone still using the group before removing it
+	f, err := openLocked(noteFilepath, os.O_RDWR, 0, true)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
 
-	return result, nil
+	if err := os.Remove(noteFilepath); err != nil {
+		return err
+	}
+	removeEmptyDirs(GroupName(groupName))
+	return nil
 }
 
-func DeleteNoteById(groupName string, id int64) error {
-	var nfh NoteFileHeader
+// removeEmptyDirs removes the directories of the subgroups of a deleted group
+// and of its parents that were left empty.
+func removeEmptyDirs(name GroupName) {
 	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return
+	}
+	for {
+		// fails for directories still holding subgroups, which are kept
+		os.Remove(path.Join(kfp, name.dirname()))
+		parent, ok := name.Parent()
+		if !ok {
+			return
+		}
+		name = parent
+	}
+}
+
+// RenameGroup renames a group, both its file and the title in its header, and
+// moves its subgroups along. Groups can be moved into another parent, as long
+// as it exists. It fails if a group named newName already exists.
+func RenameGroup(oldName, newName string) error {
+	if oldName == newName {
+		return fmt.Errorf("group %s already has that name", oldName)
+	}
+	if GroupName(oldName) == DefaultGroup {
+		return fmt.Errorf("the default group can't be renamed")
+	}
+	if GroupName(oldName).Contains(GroupName(newName)) {
+		return fmt.Errorf("group %s can't be moved into itself", oldName)
+	}
+	name, err := newGroup(newName)
 	if err != nil {
 		return err
 	}
 
-	noteFilepath := path.Join(kfp, groupName+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_RDWR, 0)
+	oldFilepath, err := groupFilepath(oldName)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	newFilepath, err := groupFilepath(newName)
+	if err != nil {
+		return err
+	}
+	if utils.DoesFileExists(newFilepath) {
+		return fmt.Errorf("group %s already exists", newName)
+	}
+	if err := os.MkdirAll(path.Dir(newFilepath), 0755); err != nil {
+		return err
+	}
 
-	if _, err = f.Seek(int64(binary.Size(nfh))+int64(binary.Size(Note{}))*(id-1), io.SeekStart); err != nil {
-		if errors.Is(err, io.EOF) {
-			return fmt.Errorf("invalid id")
-		}
-		return fmt.Errorf("invalid id %v for range: %w", id, err)
+	renames := []journaledRename{{From: GroupName(oldName).filename(), To: name.filename()}}
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return err
+	}
+	var oldDir, newDir string
+	if info, err := os.Stat(path.Join(kfp, GroupName(oldName).dirname())); err == nil && info.IsDir() {
+		oldDir, newDir = path.Join(kfp, GroupName(oldName).dirname()), path.Join(kfp, name.dirname())
+		renames = append(renames, journaledRename{From: GroupName(oldName).dirname(), To: name.dirname()})
 	}
 
-	nfh.Size--
+	g, err := openGroup(oldName, true)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
 
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
+	j, err := beginJournal(map[*groupFile][][2]int64{g: {{0, g.layout.headerSize()}}}, renames...)
+	if err != nil {
 		return err
 	}
 
-	if err := binary.Write(f, binary.BigEndian, &nfh); err != nil {
+	// linking, unlike renaming, never replaces a group created meanwhile
+	if err := os.Link(oldFilepath, newFilepath); err != nil {
+		j.discard()
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("group %s already exists", newName)
+		}
 		return err
 	}
 
-	return binary.Write(f, binary.BigEndian, &Note{Id: -1})
+	g.header.Title = name.title()
+	if err := renameGroup(g, oldFilepath, oldDir, newDir); err != nil {
+		if rerr := j.rollback(g); rerr != nil {
+			return fmt.Errorf("%w, and rolling back failed: %v", err, rerr)
+		}
+		return err
+	}
+	return j.commit()
 }
 
-func DeleteGroup(groupName string) error {
-	kfp, err := utils.GetKeepFilePath()
-	if err != nil {
+// renameGroup completes the renaming of a group once its file was linked under
+// the new name, moving the directory of its subgroups if it has one.
+func renameGroup(g *groupFile, oldFilepath, oldDir, newDir string) error {
+	if err := g.writeHeader(); err != nil {
+		return err
+	}
+	if err := g.Sync(); err != nil {
+		return err
+	}
+	if err := os.Remove(oldFilepath); err != nil {
 		return err
 	}
+	if oldDir == "" {
+		return nil
+	}
+	return os.Rename(oldDir, newDir)
+}
 
-	noteFilepath := path.Join(kfp, groupName+".kps")
+// DescribeGroup replaces the description of a group.
+func DescribeGroup(groupName, description string) error {
+	var desc [200]rune
+	if len([]rune(description)) > len(desc) {
+		return fmt.Errorf("description is longer than %v characters", len(desc))
+	}
+	copy(desc[:], []rune(description))
 
-	if !utils.DoesFileExists(noteFilepath) {
-		return fmt.Errorf("no group with given name")
+	g, err := openGroup(groupName, true)
+	if err != nil {
+		return err
 	}
+	defer g.Close()
 
-	return os.Remove(noteFilepath)
+	g.header.Description = desc
+	return g.writeHeader()
 }
 
 func GetGroups() ([]NoteFileHeader, error) {
@@ -328,17 +736,15 @@ func GetGroups() ([]NoteFileHeader, error) {
 		return groups, err
 	}
 
-	entries, err := os.ReadDir(keepFilePath)
+	names, err := storeFiles(keepFilePath)
 	if err != nil {
-		return groups, fmt.Errorf("unable to read dir: %w", err)
+		return groups, err
 	}
 
-	for _, e := range entries {
-		if isKpsFile(e) {
-			header, err := GetKpsHeader(path.Join(keepFilePath, e.Name()))
-			if err == nil {
-				groups = append(groups, header)
-			}
+	for _, name := range names {
+		header, err := GetKpsHeader(path.Join(keepFilePath, name))
+		if err == nil {
+			groups = append(groups, header)
 		}
 	}
 
@@ -351,12 +757,13 @@ func isKpsFile(entry fs.DirEntry) bool {
 
 // GetKpsHeader returns the header of a .kps binary file and a nil error if it has success.
 func GetKpsHeader(filename string) (NoteFileHeader, error) {
-	var header NoteFileHeader
-	f, err := os.Open(filename)
+	f, err := openLocked(filename, os.O_RDONLY, 0, false)
 	if err != nil {
-		return header, err
+		return NoteFileHeader{}, err
 	}
-	if err := binary.Read(f, binary.BigEndian, &header); err != nil {
+	defer f.Close()
+	header, _, err := readHeader(f)
+	if err != nil {
 		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 			return header, nil
 		}
@@ -365,49 +772,227 @@ func GetKpsHeader(filename string) (NoteFileHeader, error) {
 	return header, nil
 }
 
-func CreateSingleNote(text string) error {
-	var nfh NoteFileHeader
-	kfp, err := utils.GetKeepFilePath()
-	if err != nil {
+// CreateSingleNote adds a note to the group of the notes created without
+// group, the one default_group names, creating the group the first time.
+func CreateSingleNote(groupName, text string, opts NoteOptions) error {
+	if err := ensureDefaultGroup(groupName); err != nil {
+		return err
+	}
+	if _, err := appendNote(groupName, text, opts); err != nil {
 		return err
 	}
+	if GroupName(groupName) != DefaultGroup {
+		return nil
+	}
 
-	noteFilepath := path.Join(kfp, common.DEFAULT_KEEP_FILE_PATH+".kps")
-	f, err := os.OpenFile(noteFilepath, os.O_RDWR, 0)
+	info, err := configs.GetDefaultGroupState()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	info.Add()
+	return info.Save()
+}
 
-	if err := binary.Read(f, binary.BigEndian, &nfh); err != nil {
+// ensureDefaultGroup creates the group of the notes created without group the
+// first time one is. A group other than DefaultGroup is created as any other,
+// so its parent must exist.
+func ensureDefaultGroup(name string) error {
+	noteFilepath, err := groupFilepath(name)
+	if err != nil {
 		return err
 	}
-
-	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+	if utils.DoesFileExists(noteFilepath) {
+		return nil
+	}
+	if GroupName(name) != DefaultGroup {
+		_, err := NewNoteFile(name, "notes without group")
 		return err
 	}
+	return createGroup(name, NewNoteFileHeader(name, "notes without group", 0, 0))
+}
+
+// MarkDone marks a note of the group as done. When the note repeats, its next
+// instance is added right away and its id returned, otherwise 0 is.
+func MarkDone(groupName string, id int64) (int64, error) {
+	return setDone(groupName, id, true, time.Now())
+}
 
-	note := NewNote(int64(nfh.SizeAlltime)+1, text, utils.RandomColor(), time.Now().UnixMilli())
+// MarkUndone marks a note of the group as open again.
+func MarkUndone(groupName string, id int64) error {
+	_, err := setDone(groupName, id, false, time.Now())
+	return err
+}
 
-	if err := binary.Write(f, binary.BigEndian, &note); err != nil {
-		return err
+func setDone(groupName string, id int64, done bool, now time.Time) (int64, error) {
+	g, err := openGroup(groupName, true)
+	if err != nil {
+		return 0, err
 	}
+	defer g.Close()
 
-	nfh.Size++
-	nfh.SizeAlltime++
+	note, err := g.readLiveNote(id)
+	if err != nil {
+		return 0, err
+	}
+	if note.Done() == done {
+		return 0, nil
+	}
+
+	note.DoneAt = 0
+	if done {
+		note.DoneAt = now.UnixMilli()
+	}
 
-	if _, err = f.Seek(0, io.SeekStart); err != nil {
+	if rule, err := note.RepeatRule(); done && err == nil && rule != nil {
+		prev := note.DueTime()
+		if prev.IsZero() {
+			prev = time.UnixMilli(note.CreatedAt)
+		}
+		if at := rule.Next(prev); !at.IsZero() {
+			next, err := g.repeat(&note, at, now)
+			if err != nil {
+				return 0, err
+			}
+			return next.Id, nil
+		}
+	}
+
+	return 0, g.writeNote(id, &note)
+}
+
+// SetPinned pins a note of the group, listing it before the rest, or unpins
+// it.
+func SetPinned(groupName string, id int64, pinned bool) error {
+	g, err := openGroup(groupName, true)
+	if err != nil {
 		return err
 	}
+	defer g.Close()
 
-	if err := binary.Write(f, binary.BigEndian, &nfh); err != nil {
+	note, err := g.readLiveNote(id)
+	if err != nil {
 		return err
 	}
+	note.Pinned = pinned
+	return g.writeNote(id, &note)
+}
 
-	info := configs.GetDefaultGroupState()
+// Progress returns how many live notes of the group are done and how many
+// there are. Encrypted groups are counted without decrypting their notes.
+func Progress(groupName string) (done, total int, err error) {
+	_, groupNotes, err := ReadGroup(groupName)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, n := range groupNotes {
+		if n.Done() {
+			done++
+		}
+	}
+	return done, len(groupNotes), nil
+}
 
-	info.Add()
-	info.Save()
+// Search returns the notes of all groups whose text contains term, ignoring
+// case, keyed by group name. Encrypted groups are searched too, so their
+// passphrase is asked for.
+func Search(term string) (map[string][]Note, error) {
+	term = strings.ToLower(term)
+	return Collect(func(_ string, n Note) bool {
+		return strings.Contains(strings.ToLower(n.String()), term)
+	})
+}
 
-	return nil
+// DueNotes returns the notes of all groups that have a due date and aren't
+// done, keyed by group name. Only the encrypted groups holding such notes are
+// decrypted, and those that can't be are skipped with a *LockedError.
+func DueNotes() (map[string][]Note, error) {
+	return collectStored(func(n Note) bool { return n.Due != 0 && !n.Done() })
+}
+
+// RecurringNotes returns the notes of all groups that repeat, keyed by group
+// name. Only the latest instance of a repeating note holds its rule. Locked
+// groups are skipped as by DueNotes.
+func RecurringNotes() (map[string][]Note, error) {
+	return collectStored(func(n Note) bool { return n.Repeats() })
+}
+
+// collectStored is like Collect, but matches the notes as stored, i.e. by
+// their metadata, and only decrypts the ones that match.
+func collectStored(match func(n Note) bool) (map[string][]Note, error) {
+	result := map[string][]Note{}
+
+	groupNames, err := GroupNames()
+	if err != nil {
+		return result, err
+	}
+
+	var locked LockedError
+	for _, groupName := range groupNames {
+		matched, err := readMatching(groupName, match)
+		if locked.skip(groupName, err) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if len(matched) > 0 {
+			result[groupName] = matched
+		}
+	}
+	return result, locked.orNil()
+}
+
+// readMatching returns the live notes of a group that match as stored,
+// decrypted.
+func readMatching(groupName string, match func(n Note) bool) ([]Note, error) {
+	g, err := openGroup(groupName, false)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Close()
+
+	records, err := g.records()
+	if err != nil {
+		return nil, err
+	}
+	var matched []Note
+	for _, n := range records {
+		if n.Id <= 0 || !match(n) {
+			continue
+		}
+		if err := g.open(&n); err != nil {
+			return nil, err
+		}
+		matched = append(matched, n)
+	}
+	return matched, nil
+}
+
+// Collect returns the notes of all groups that match, keyed by group name. The
+// encrypted groups that can't be unlocked are skipped with a *LockedError.
+func Collect(match func(group string, n Note) bool) (map[string][]Note, error) {
+	result := map[string][]Note{}
+
+	groupNames, err := GroupNames()
+	if err != nil {
+		return result, err
+	}
+
+	var locked LockedError
+	for _, groupName := range groupNames {
+		notes, err := ReadAllNotes(groupName)
+		if locked.skip(groupName, err) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		for n := range notes {
+			if match(groupName, n) {
+				result[groupName] = append(result[groupName], n)
+			}
+		}
+	}
+
+	return result, locked.orNil()
 }
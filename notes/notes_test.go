@@ -0,0 +1,1001 @@
+package notes_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/configs"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/recur"
+	"github.com/DavidEsdrs/keep/utils"
+	"github.com/fatih/color"
+)
+
+func setupStore(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return kfp
+}
+
+func readGroup(t *testing.T, group string) []notes.Note {
+	t.Helper()
+	ch, err := notes.ReadAllNotes(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []notes.Note
+	for n := range ch {
+		result = append(result, n)
+	}
+	return result
+}
+
+// layout of the files written before format versions existed
+type headerV1 struct {
+	Title       [20]rune
+	Description [200]rune
+	Size        uint32
+	SizeAlltime uint32
+	CreatedAt   int64
+}
+
+type noteV1 struct {
+	Id        int64
+	Text      [300]rune
+	Color     int32
+	CreatedAt int64
+}
+
+func writeV1Group(t *testing.T, kfp, group string, texts ...string) {
+	t.Helper()
+	f, err := os.Create(path.Join(kfp, group+".kps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	header := headerV1{Size: uint32(len(texts)), SizeAlltime: uint32(len(texts))}
+	copy(header.Title[:], []rune(group))
+	if err := binary.Write(f, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	for i, text := range texts {
+		n := noteV1{Id: int64(i + 1), Color: 36, CreatedAt: 1700000000000}
+		copy(n.Text[:], []rune(text))
+		if err := binary.Write(f, binary.BigEndian, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadAndUpgradeV1Group(t *testing.T) {
+	kfp := setupStore(t)
+	writeV1Group(t, kfp, "books", "Programming Language Pragmatics", "Crafting Interpreters")
+
+	header, err := notes.GetGroupHeader("books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Size != 2 || !strings.HasPrefix(string(header.Title[:]), "books") {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	note, err := notes.GetNoteById("books", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.String() != "Crafting Interpreters" {
+		t.Fatalf("unexpected note %q", note.String())
+	}
+
+	// writing upgrades the file to the current format
+	if err := notes.AddNote("books", "The Go Programming Language"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readGroup(t, "books")
+	if len(got) != 3 {
+		t.Fatalf("expected 3 notes, got %v", len(got))
+	}
+	for i, want := range []string{"Programming Language Pragmatics", "Crafting Interpreters", "The Go Programming Language"} {
+		if got[i].Id != int64(i+1) || got[i].String() != want {
+			t.Fatalf("unexpected note %v: %v %q", i, got[i].Id, got[i].String())
+		}
+	}
+
+	f, err := os.Open(path.Join(kfp, "books.kps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var prefix [2]uint32
+	if err := binary.Read(f, binary.BigEndian, &prefix); err != nil {
+		t.Fatal(err)
+	}
+	if prefix[1] != notes.FormatVersion {
+		t.Fatalf("expected format version %v, got %v", notes.FormatVersion, prefix[1])
+	}
+}
+
+func TestUpgradeV9Origin(t *testing.T) {
+	kfp := setupStore(t)
+	f, err := os.Create(path.Join(kfp, "work.kps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := notes.NewNoteFileHeader("work", "", 1, 1)
+	prefix := [2]uint32{0x4B505300, 9}
+	if err := binary.Write(f, binary.BigEndian, &prefix); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(f, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+	// version 9 ended notes with the origin group in 20 runes and its id
+	n := notes.NewNote(1, "Fix the build", color.FgWhite, 1700000000000)
+	var record strings.Builder
+	if err := binary.Write(&record, binary.BigEndian, &n); err != nil {
+		t.Fatal(err)
+	}
+	origin := struct {
+		Group [20]rune
+		Id    int64
+	}{Id: 4}
+	copy(origin.Group[:], []rune("inbox"))
+	if _, err := f.WriteString(record.String()[:1357]); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(f, binary.BigEndian, &origin); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got := readGroup(t, "work")
+	if group, id := got[0].Origin(); len(got) != 1 || got[0].String() != "Fix the build" || group != "inbox" || id != 4 {
+		t.Fatalf("unexpected notes %+v", got)
+	}
+}
+
+func TestEncryptedGroup(t *testing.T) {
+	kfp := setupStore(t)
+	defer func() { notes.Passphrase = nil }()
+
+	if _, err := notes.NewEncryptedNoteFile("secrets", "customer names", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("secrets", "ACME Corporation"); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path.Join(kfp, "secrets.kps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, 4)
+	binary.BigEndian.PutUint32(plain, 'A')
+	if strings.Contains(string(content), string(append(plain, 0, 0, 0, 'C'))) {
+		t.Fatal("note text stored in plaintext")
+	}
+
+	header, err := notes.GetGroupHeader("secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Encrypted() {
+		t.Fatal("group header not marked as encrypted")
+	}
+
+	note, err := notes.GetNoteById("secrets", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.String() != "ACME Corporation" {
+		t.Fatalf("unexpected note %q", note.String())
+	}
+
+	found, err := notes.Search("acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found["secrets"]) != 1 {
+		t.Fatalf("expected note to be found, got %v", found)
+	}
+	lockedGroup(t, "diary", map[string]notes.NoteOptions{"Dear diary, acme again": {}})
+	found, err = notes.Search("acme")
+	var locked *notes.LockedError
+	if !errors.As(err, &locked) || len(locked.Groups) != 1 || locked.Groups[0] != "diary" {
+		t.Fatalf("expected diary to be skipped, got %v", err)
+	}
+	if len(found["secrets"]) != 1 || len(found["diary"]) != 0 {
+		t.Fatalf("unexpected search results %v", found)
+	}
+}
+
+func TestEncryptedTextsBoundToNotes(t *testing.T) {
+	setupStore(t)
+	defer func() { notes.Passphrase = nil }()
+
+	if _, err := notes.NewEncryptedNoteFile("secrets", "", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"ACME Corporation", "Globex"} {
+		if err := notes.AddNote("secrets", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// swap the sealed texts of both notes
+	header, stored, err := notes.ReadGroup("secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Flags&notes.FlagBound == 0 {
+		t.Fatal("new encrypted group not bound")
+	}
+	a, b := &stored[0], &stored[1]
+	a.Text, b.Text = b.Text, a.Text
+	a.Nonce, b.Nonce = b.Nonce, a.Nonce
+	a.Tag, b.Tag = b.Tag, a.Tag
+	if err := notes.WriteGroup("secrets", header, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := notes.GetNoteById("secrets", 1); !errors.Is(err, notes.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+	if _, err := notes.ReadAllNotes("secrets"); !errors.Is(err, notes.ErrCorrupt) {
+		t.Fatalf("expected reading all notes to fail with ErrCorrupt, got %v", err)
+	}
+
+	// groups encrypted before texts were bound still read
+	header.Flags &^= notes.FlagBound
+	if err := notes.WriteGroup("secrets", header, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("secrets", "Initech"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readGroup(t, "secrets"); len(got) != 1 || got[0].String() != "Initech" {
+		t.Fatalf("unexpected notes %v", got)
+	}
+}
+
+func TestNoteUIDs(t *testing.T) {
+	kfp := setupStore(t)
+	writeV1Group(t, kfp, "books", "Programming Language Pragmatics")
+
+	first, err := notes.GetNoteById("books", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.UID.IsZero() {
+		t.Fatal("upgraded note has no uid")
+	}
+	again, err := notes.GetNoteById("books", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.UID != first.UID {
+		t.Fatal("uid changed between reads")
+	}
+
+	byUID, err := notes.GetNote("books", first.UID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byUID.Id != 1 {
+		t.Fatalf("expected note 1, got %v", byUID.Id)
+	}
+
+	uid, err := notes.ParseUID(first.UID.String())
+	if err != nil || uid != first.UID {
+		t.Fatalf("uid doesn't round trip: %v", err)
+	}
+	if uid.Time().UnixMilli() != first.CreatedAt {
+		t.Fatal("uid doesn't hold the creation time of the note")
+	}
+}
+
+// lockedGroup creates an encrypted group holding the given notes that can't be
+// unlocked, as if its passphrase were unknown.
+func lockedGroup(t *testing.T, name string, texts map[string]notes.NoteOptions) {
+	t.Helper()
+	if _, err := notes.NewEncryptedNoteFile(name, "", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for text, opts := range texts {
+		if _, err := notes.AddNoteWith(name, text, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a salt no key was derived for
+	header, stored, err := notes.ReadGroup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Salt[0] ^= 0xff
+	if err := notes.WriteGroup(name, header, stored); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDueNotes(t *testing.T) {
+	setupStore(t)
+	if _, err := notes.NewNoteFile("books", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("books", "Crafting Interpreters"); err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	added, err := notes.AddNoteWith("books", "Return the books", notes.NoteOptions{Due: due, RemindBefore: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Id != 2 || !added.DueTime().Equal(due) || !added.RemindTime().Equal(due.Add(-time.Hour)) {
+		t.Fatalf("unexpected note %+v", added)
+	}
+
+	// done notes aren't due anymore, however overdue
+	overdue := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	if _, err := notes.AddNoteWith("books", "Pay the fine", notes.NoteOptions{Due: overdue}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.MarkDone("books", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := notes.DueNotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found["books"]) != 1 || found["books"][0].UID != added.UID {
+		t.Fatalf("expected the due note only, got %v", found)
+	}
+
+	if _, err := notes.AddNoteWith("books", "Someday", notes.NoteOptions{RemindBefore: time.Hour}); err == nil {
+		t.Fatal("expected an error for a reminder without due date")
+	}
+
+	// locked groups are only skipped when they hold due notes
+	lockedGroup(t, "diary", map[string]notes.NoteOptions{"Dear diary": {}})
+	if _, err := notes.DueNotes(); err != nil {
+		t.Fatalf("expected a locked group without due notes to be left alone, got %v", err)
+	}
+	lockedGroup(t, "secrets", map[string]notes.NoteOptions{"Call the lawyer": {Due: due}})
+	found, err = notes.DueNotes()
+	var locked *notes.LockedError
+	if !errors.As(err, &locked) || len(locked.Groups) != 1 || locked.Groups[0] != "secrets" {
+		t.Fatalf("expected secrets to be skipped, got %v", err)
+	}
+	if len(found["books"]) != 1 || len(found["secrets"]) != 0 {
+		t.Fatalf("unexpected due notes %v", found)
+	}
+}
+
+func TestRecurringNotes(t *testing.T) {
+	setupStore(t)
+	defer func() { notes.Passphrase = nil }()
+
+	if _, err := notes.NewEncryptedNoteFile("chores", "", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	daily, err := recur.Parse("daily")
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2026, 10, 10, 9, 0, 0, 0, time.UTC)
+	if _, err := notes.AddNoteWith("chores", "Water the plants", notes.NoteOptions{Due: due, Repeat: daily}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	added, err := notes.MaterializeRecurring(now, false)
+	var locked *notes.LockedError
+	if !errors.As(err, &locked) || len(locked.Groups) != 1 || locked.Groups[0] != "chores" || added != 0 {
+		t.Fatalf("expected chores to be left alone, got %v %v", added, err)
+	}
+	added, err = notes.MaterializeRecurring(now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 {
+		t.Fatalf("expected 1 note added, got %v", added)
+	}
+	if added, _ := notes.MaterializeRecurring(now, true); added != 0 {
+		t.Fatalf("expected nothing more to add, got %v", added)
+	}
+
+	got := readGroup(t, "chores")
+	if len(got) != 2 || got[0].Repeats() || !got[1].Repeats() {
+		t.Fatalf("expected the rule to move to the new note, got %+v", got)
+	}
+	if got[1].String() != "Water the plants" || !got[1].DueTime().Equal(time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected new note %q due %v", got[1].String(), got[1].DueTime())
+	}
+	if !got[0].DueTime().IsZero() {
+		t.Fatalf("expected the previous note not to be due anymore, got %v", got[0].DueTime())
+	}
+	if got[1].UID == got[0].UID {
+		t.Fatal("new note has the uid of the previous one")
+	}
+}
+
+func TestDone(t *testing.T) {
+	setupStore(t)
+	if _, err := notes.NewNoteFile("todo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("todo", "Buy milk"); err != nil {
+		t.Fatal(err)
+	}
+	weekly, err := recur.Parse("weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC)
+	if _, err := notes.AddNoteWith("todo", "Take out the trash", notes.NoteOptions{Due: due, Repeat: weekly}); err != nil {
+		t.Fatal(err)
+	}
+
+	if next, err := notes.MarkDone("todo", 1); err != nil || next != 0 {
+		t.Fatalf("unexpected result %v %v", next, err)
+	}
+	next, err := notes.MarkDone("todo", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 3 {
+		t.Fatalf("expected the next trash day to be added as note 3, got %v", next)
+	}
+
+	got := readGroup(t, "todo")
+	if len(got) != 3 || !got[0].Done() || !got[1].Done() || got[2].Done() {
+		t.Fatalf("unexpected notes %+v", got)
+	}
+	if !got[2].DueTime().Equal(due.AddDate(0, 0, 7)) || !got[2].Repeats() || got[1].Repeats() {
+		t.Fatalf("unexpected next note due %v", got[2].DueTime())
+	}
+
+	if done, total, err := notes.Progress("todo"); err != nil || done != 2 || total != 3 {
+		t.Fatalf("expected 2/3 done, got %v/%v %v", done, total, err)
+	}
+
+	if err := notes.MarkUndone("todo", 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := notes.GetNoteById("todo", 1); n.Done() {
+		t.Fatal("note still done")
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	kfp := setupStore(t)
+	writeV1Group(t, kfp, "work", "Written before priorities")
+
+	if _, err := notes.AddNoteWith("work", "Fix the build", notes.NoteOptions{Priority: notes.PriorityUrgent}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.AddNoteWith("work", "Tidy the desk", notes.NoteOptions{Priority: notes.PriorityLow, Color: color.FgGreen}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readGroup(t, "work")
+	if got[0].Priority != notes.PriorityNormal || got[0].DisplayColor() != 36 {
+		t.Fatalf("old note doesn't keep its color: %v %v", got[0].Priority, got[0].DisplayColor())
+	}
+	if got[1].DisplayColor() != notes.DefaultPriorityColors[notes.PriorityUrgent] {
+		t.Fatalf("expected the color of urgent notes, got %v", got[1].DisplayColor())
+	}
+	if got[2].DisplayColor() != color.FgGreen {
+		t.Fatalf("expected the given color, got %v", got[2].DisplayColor())
+	}
+
+	notes.Sort(got, notes.SortByPriority)
+	if got[0].Id != 2 || got[1].Id != 1 || got[2].Id != 3 {
+		t.Fatalf("unexpected order %v %v %v", got[0].Id, got[1].Id, got[2].Id)
+	}
+
+	if p, err := notes.ParsePriority("High"); err != nil || p != notes.PriorityHigh {
+		t.Fatalf("unexpected priority %v %v", p, err)
+	}
+}
+
+func TestPinned(t *testing.T) {
+	setupStore(t)
+	if _, err := notes.NewNoteFile("work", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"Fix the build", "On-call: 555-0100", "Tidy the desk"} {
+		if _, err := notes.AddNoteWith("work", text, notes.NoteOptions{Priority: notes.PriorityHigh}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.SetPinned("work", 3, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.SetPinned("work", 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.SetPinned("work", 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, order := range []notes.SortOrder{notes.SortById, notes.SortByPriority} {
+		got := readGroup(t, "work")
+		notes.Sort(got, order)
+		if got[0].Id != 2 || !got[0].Pinned || got[1].Id != 1 || got[2].Id != 3 || got[2].Pinned {
+			t.Fatalf("pinned note isn't first when sorting by %v: %v %v %v", order, got[0].Id, got[1].Id, got[2].Id)
+		}
+	}
+
+	if _, err := notes.ParseSortOrder("date"); err == nil {
+		t.Fatal("expected an unknown sort order to fail")
+	}
+}
+
+func TestMoveNote(t *testing.T) {
+	setupStore(t)
+	defer func() { notes.Passphrase = nil }()
+	for _, name := range []string{"inbox", "work"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := notes.NewEncryptedNoteFile("secrets", "", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"Fix the build", "On-call: 555-0100"} {
+		if err := notes.AddNote("inbox", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.AddNote("work", "Standup at 10"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := notes.GetNoteById("inbox", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := notes.MoveNote("inbox", 2, "work", notes.MoveOptions{Ref: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group, id := moved.Origin(); moved.Id != 2 || moved.UID != before.UID || group != "inbox" || id != 2 {
+		t.Fatalf("unexpected moved note %v %v %v %v", moved.Id, moved.UID, group, id)
+	}
+	if got := readGroup(t, "inbox"); len(got) != 1 || got[0].Id != 1 {
+		t.Fatalf("moved note still in its group: %v", got)
+	}
+	header, err := notes.GetGroupHeader("inbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Size != 1 || header.SizeAlltime != 2 {
+		t.Fatalf("unexpected source header sizes %v %v", header.Size, header.SizeAlltime)
+	}
+	if got := readGroup(t, "work"); len(got) != 2 || got[1].String() != "On-call: 555-0100" {
+		t.Fatalf("unexpected notes %v", got)
+	}
+
+	notes.Passphrase = func(string) (string, error) { return "correct horse", nil }
+	copied, err := notes.MoveNote("inbox", 1, "secrets", notes.MoveOptions{Copy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group, _ := copied.Origin(); copied.Id != 1 || group != "" {
+		t.Fatalf("unexpected copied note %v %v", copied.Id, group)
+	}
+	if got := readGroup(t, "inbox"); len(got) != 1 || got[0].UID == copied.UID {
+		t.Fatalf("copied note not left in its group or with the same UID: %v", got)
+	}
+	note, err := notes.GetNoteById("secrets", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.String() != "Fix the build" {
+		t.Fatalf("unexpected copied text %q", note.String())
+	}
+
+	if _, err := notes.MoveNote("work", 1, "work", notes.MoveOptions{}); err == nil {
+		t.Fatal("expected moving a note to its own group to fail")
+	}
+}
+
+func TestRenameGroup(t *testing.T) {
+	kfp := setupStore(t)
+	for _, name := range []string{"bokos", "movies"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.AddNote("bokos", "Crafting Interpreters"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := notes.RenameGroup("bokos", "movies"); err == nil {
+		t.Fatal("expected renaming over an existing group to fail")
+	}
+	if err := notes.RenameGroup("bokos", "books"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.DescribeGroup("books", "books to read"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path.Join(kfp, "bokos.kps")); !os.IsNotExist(err) {
+		t.Fatalf("old group file left behind: %v", err)
+	}
+	header, groupNotes, err := notes.ReadGroup("books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	title := strings.TrimRight(string(header.Title[:]), "\x00")
+	description := strings.TrimRight(string(header.Description[:]), "\x00")
+	if title != "books" || description != "books to read" || len(groupNotes) != 1 {
+		t.Fatalf("unexpected group %q %q %v", title, description, groupNotes)
+	}
+	if names, err := notes.GroupNames(); err != nil || len(names) != 2 {
+		t.Fatalf("unexpected groups %v %v", names, err)
+	}
+}
+
+func TestGroupNames(t *testing.T) {
+	kfp := setupStore(t)
+
+	for _, name := range []string{"books", "to-do list", "2026_q4", "v1.2", "Bücher"} {
+		if _, err := notes.NewGroupName(name); err != nil {
+			t.Fatalf("valid name %q rejected: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../../tmp/x", "a/b", `a\b`, ".hidden", " books", "books!", "info", "INFO", "keeps.txt", "a name way longer than twenty"} {
+		if _, err := notes.NewNoteFile(name, ""); err == nil {
+			t.Fatalf("invalid name %q accepted", name)
+		}
+	}
+	if err := notes.AddNote("../../tmp/x", "escaped"); err == nil {
+		t.Fatal("expected adding to an invalid group to fail")
+	}
+	if err := notes.DeleteGroup("../info"); err == nil {
+		t.Fatal("expected deleting an invalid group to fail")
+	}
+
+	if err := notes.CreateSingleNote(string(notes.DefaultGroup), "loose note", notes.NoteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.RenameGroup(string(notes.DefaultGroup), "loose"); err == nil {
+		t.Fatal("expected renaming the default group to fail")
+	}
+	if _, err := notes.NewNoteFile("books", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.RenameGroup("books", "info"); err == nil {
+		t.Fatal("expected renaming to a reserved name to fail")
+	}
+
+	entries, err := os.ReadDir(kfp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "books.kps" && e.Name() != string(notes.DefaultGroup)+".kps" && e.Name() != "info.kps" {
+			t.Fatalf("unexpected file %s in the store", e.Name())
+		}
+	}
+}
+
+func TestSubgroups(t *testing.T) {
+	kfp := setupStore(t)
+	if _, err := notes.NewNoteFile("work/projectA", ""); err == nil {
+		t.Fatal("expected creating a subgroup without its parent to fail")
+	}
+	for _, name := range []string{"work", "work/projectA", "work/projectA/meetings", "work-log"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.AddNote("work/projectA/meetings", "Standup at 10"); err != nil {
+		t.Fatal(err)
+	}
+
+	header, groupNotes, err := notes.ReadGroup("work/projectA/meetings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := strings.TrimRight(string(header.Title[:]), "\x00"); title != "meetings" || len(groupNotes) != 1 {
+		t.Fatalf("unexpected subgroup %q %v", title, groupNotes)
+	}
+	subgroups, err := notes.Subgroups("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(subgroups, ",") != "work/projectA,work/projectA/meetings" {
+		t.Fatalf("unexpected subgroups %v", subgroups)
+	}
+
+	if err := notes.DeleteGroup("work"); !errors.Is(err, notes.ErrHasSubgroups) {
+		t.Fatalf("expected deleting a group with subgroups to fail, got %v", err)
+	}
+	if err := notes.RenameGroup("work/projectA", "work/projectB"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readGroup(t, "work/projectB/meetings"); len(got) != 1 {
+		t.Fatalf("subgroup not renamed along its parent: %v", got)
+	}
+	if err := notes.RenameGroup("work", "work/projectB/work"); err == nil {
+		t.Fatal("expected moving a group into its own subgroup to fail")
+	}
+
+	if err := notes.DeleteGroupTree("work"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := notes.GroupNames(); err != nil || len(names) != 1 || names[0] != "work-log" {
+		t.Fatalf("unexpected groups left %v %v", names, err)
+	}
+	if _, err := os.Stat(path.Join(kfp, "work")); !os.IsNotExist(err) {
+		t.Fatalf("directory of subgroups left behind: %v", err)
+	}
+}
+
+func TestMoveNoteFromSubgroup(t *testing.T) {
+	setupStore(t)
+	for _, name := range []string{"work", "work/projectAlpha", "work/projectAlpha/meetings"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.AddNote("work/projectAlpha/meetings", "Standup at 10"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := notes.MoveNote("work/projectAlpha/meetings", 1, "work", notes.MoveOptions{Ref: true}); err != nil {
+		t.Fatal(err)
+	}
+	note, err := notes.GetNoteById("work", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group, id := note.Origin(); group != "work/projectAlpha/meetings" || id != 1 {
+		t.Fatalf("unexpected origin %q %v", group, id)
+	}
+
+	if err := note.SetOrigin(strings.Repeat("a", notes.MaxGroupPathLength+1), 1); err == nil {
+		t.Fatal("expected an origin longer than any group name to fail")
+	}
+}
+
+func TestSmartGroups(t *testing.T) {
+	setupStore(t)
+	for _, name := range []string{"work", "work/projectA", "home"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	urgent := notes.NoteOptions{Priority: notes.PriorityUrgent}
+	for _, n := range []struct{ group, text string }{
+		{"work", "Fix the build #work"},
+		{"work", "Deploy the fix #Work, #release"},
+		{"work/projectA", "Review the design #work"},
+		{"home", "Pay rent #home"},
+	} {
+		if _, err := notes.AddNoteWith(n.group, n.text, urgent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := notes.MarkDone("work", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := notes.NewSmartGroup("urgent-work", "tag:work priority:urgent -done"); err != nil {
+		t.Fatal(err)
+	}
+	header, err := notes.GetGroupHeader("urgent-work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !header.Smart() || header.Query() != "tag:work priority:urgent -done" {
+		t.Fatalf("unexpected smart group header %v %q", header.Smart(), header.Query())
+	}
+
+	deploy, err := notes.GetNoteById("work", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := deploy.Tags(); strings.Join(tags, ",") != "work,release" {
+		t.Fatalf("unexpected tags %v", tags)
+	}
+	if !deploy.HasTag("#Release") {
+		t.Fatal("expected tags to ignore case")
+	}
+
+	if err := notes.AddNote("urgent-work", "Not here"); err == nil {
+		t.Fatal("expected adding a note to a smart group to fail")
+	}
+	if _, err := notes.MoveNote("home", 1, "urgent-work", notes.MoveOptions{}); err == nil {
+		t.Fatal("expected moving a note into a smart group to fail")
+	}
+}
+
+func TestStats(t *testing.T) {
+	setupStore(t)
+	for _, name := range []string{"books", "work"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range []struct{ group, text string }{
+		{"books", "Crafting Interpreters #fav"},
+		{"books", "Dune #fav #scifi"},
+		{"books", "Hyperion #scifi"},
+		{"work", "A note long enough to fall into another bucket #fav"},
+	} {
+		if err := notes.AddNote(n.group, n.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.DeleteNoteById("books", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.MarkDone("work", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := notes.GroupStats("books", "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Groups != 2 || s.Total != 4 || s.Live != 3 || s.Deleted != 1 || s.Done != 1 {
+		t.Fatalf("unexpected counts %+v", s)
+	}
+	if s.Reclaimable <= 0 || s.Reclaimable >= s.Bytes {
+		t.Fatalf("unexpected sizes %v of %v", s.Reclaimable, s.Bytes)
+	}
+	if len(s.TopTags) != 2 || s.TopTags[0] != (notes.TagCount{Tag: "fav", Count: 3}) || s.TopTags[1].Tag != "scifi" {
+		t.Fatalf("unexpected tags %+v", s.TopTags)
+	}
+	if s.Lengths[0].Count != 1 || s.Lengths[1].Count != 1 || s.Lengths[2].Count != 1 {
+		t.Fatalf("unexpected lengths %+v", s.Lengths)
+	}
+	if s.Oldest == nil || s.Oldest.Group != "books" || s.Oldest.Id != 1 || s.Newest == nil || s.Newest.Group != "work" {
+		t.Fatalf("unexpected oldest and newest notes %+v %+v", s.Oldest, s.Newest)
+	}
+	// a day of notes is no more than those notes in a week
+	if s.PerDay != 3 || s.PerWeek != 3 {
+		t.Fatalf("unexpected rates %v %v", s.PerDay, s.PerWeek)
+	}
+
+	// smart groups aren't counted, and only the stored counts of a locked
+	// group are
+	if _, err := notes.NewSmartGroup("favs", "tag:fav"); err != nil {
+		t.Fatal(err)
+	}
+	lockedGroup(t, "diary", map[string]notes.NoteOptions{"Dear diary #secret": {}})
+	s, err = notes.GroupStats("books", "work", "favs", "diary")
+	var locked *notes.LockedError
+	if !errors.As(err, &locked) || len(locked.Groups) != 1 || locked.Groups[0] != "diary" {
+		t.Fatalf("expected diary to be skipped, got %v", err)
+	}
+	if s.Groups != 3 || s.Live != 4 || s.Newest.Group != "diary" || s.Newest.Text != "" {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if len(s.TopTags) != 2 {
+		t.Fatalf("expected the tags of diary to be left out, got %+v", s.TopTags)
+	}
+}
+
+func TestRender(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	header := notes.NewNoteFileHeader("books", "to read", 0, 0)
+	header.CreatedAt = time.Date(2026, 9, 28, 10, 0, 0, 0, time.UTC).UnixMilli()
+	note := notes.NewNote(3, "Dune", color.FgCyan, time.Date(2026, 10, 16, 8, 30, 0, 0, time.UTC).UnixMilli())
+	note.Pinned = true
+	note.Due = time.Date(2026, 10, 15, 18, 0, 0, 0, time.UTC).UnixMilli()
+
+	render := func() string {
+		var buf strings.Builder
+		header.Render(&buf, "books", "1/2 done", now)
+		note.Render(&buf, now)
+		return buf.String()
+	}
+
+	color.NoColor = true
+	want := "books                ~  09/28/2026  -  to read  1/2 done\n" +
+		"3 ~  8:30AM  - 📌 [ ] Dune (due Thu Oct 15 18:00)\n"
+	if got := render(); got != want {
+		t.Fatalf("unexpected plain output:\n%q\nexpected:\n%q", got, want)
+	}
+
+	color.NoColor = false
+	want = "books                ~ \x1b[104;1m 09/28/2026 \x1b[0;22m - \x1b[97;1m to read \x1b[0;22m\x1b[90m 1/2 done\x1b[0m\n" +
+		"3 ~ \x1b[104;1m 8:30AM \x1b[0;22m - \x1b[36;1m📌 [ ] Dune\x1b[0;22m\x1b[91m (due Thu Oct 15 18:00)\x1b[0m\n"
+	if got := render(); got != want {
+		t.Fatalf("unexpected colored output:\n%q\nexpected:\n%q", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	kfp := setupStore(t)
+	if _, err := notes.NewNoteFile("books", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"Dune", "Solaris"} {
+		if err := notes.AddNote("books", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.DeleteNoteById("books", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		err  error
+		want error
+	}{
+		{second(notes.GetNoteById("movies", 1)), notes.ErrGroupNotFound},
+		{notes.DeleteNoteById("movies", 1), notes.ErrGroupNotFound},
+		{second(notes.GetNoteById("books", 3)), notes.ErrNoteNotFound},
+		{second(notes.GetNote("books", "dune")), notes.ErrInvalidNoteRef},
+		{second(notes.GetNote("books", "01a152c7-2896-7526-96fe-cb2042648836")), notes.ErrNoteNotFound},
+		{second(notes.GetNoteById("books", 1)), notes.ErrNoteDeleted},
+		{notes.DeleteNoteById("books", 1), notes.ErrNoteDeleted},
+		{notes.SetPinned("books", 1, true), notes.ErrNoteDeleted},
+	} {
+		if !errors.Is(c.err, c.want) {
+			t.Fatalf("expected %v, got %v", c.want, c.err)
+		}
+	}
+
+	// a group cut short
+	content, err := os.ReadFile(path.Join(kfp, "books.kps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(kfp, "books.kps"), content[:30], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.GetNoteById("books", 2); !errors.Is(err, notes.ErrCorrupt) {
+		t.Fatalf("expected %v, got %v", notes.ErrCorrupt, err)
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestDefaultPriorityColorsMatchSettings(t *testing.T) {
+	t.Setenv("KEEP_CONFIG", path.Join(t.TempDir(), "config.toml"))
+	for _, s := range configs.Settings {
+		os.Unsetenv(s.Env())
+	}
+	c, err := configs.Load(utils.DefaultProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, key := range map[notes.Priority]string{
+		notes.PriorityLow:    configs.ColorLow,
+		notes.PriorityNormal: configs.ColorNormal,
+		notes.PriorityHigh:   configs.ColorHigh,
+		notes.PriorityUrgent: configs.ColorUrgent,
+	} {
+		attr, err := utils.ParseColor(c.Get(key))
+		if err != nil || attr != notes.DefaultPriorityColors[p] {
+			t.Fatalf("default of %s is %q, not the default color of %v", key, c.Get(key), p)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package notes
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/DavidEsdrs/keep/utils"
+	"github.com/fatih/color"
+)
+
+// Priority of a note. The zero value is normal, so notes written before
+// priorities existed are normal ones.
+type Priority int32
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+	PriorityUrgent Priority = 2
+)
+
+var priorityNames = map[Priority]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("priority(%d)", int32(p))
+}
+
+// ParsePriority returns the priority of the given name.
+func ParsePriority(name string) (Priority, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for p, n := range priorityNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q, expected low, normal, high or urgent", name)
+}
+
+// DefaultPriorityColors are the colors of notes without a color of their own,
+// by priority.
+var DefaultPriorityColors = map[Priority]color.Attribute{
+	PriorityLow:    color.FgBlue,
+	PriorityNormal: color.FgCyan,
+	PriorityHigh:   color.FgYellow,
+	PriorityUrgent: color.FgRed,
+}
+
+var (
+	priorityColorsOnce sync.Once
+	priorityColors     map[Priority]color.Attribute
+)
+
+// ConfiguredPriorityColors, when set, overrides DefaultPriorityColors, e.g.
+// with the colors of the config file.
+var ConfiguredPriorityColors map[Priority]color.Attribute
+
+// PriorityColors returns the color scheme of priorities: DefaultPriorityColors
+// overridden by ConfiguredPriorityColors and then by the KEEP_PRIORITY_COLORS
+// environment variable, e.g. "low=gray,urgent=magenta". Invalid entries are
+// ignored.
+func PriorityColors() map[Priority]color.Attribute {
+	priorityColorsOnce.Do(func() {
+		priorityColors = map[Priority]color.Attribute{}
+		for p, c := range DefaultPriorityColors {
+			priorityColors[p] = c
+		}
+		for p, c := range ConfiguredPriorityColors {
+			priorityColors[p] = c
+		}
+		for _, entry := range strings.Split(os.Getenv("KEEP_PRIORITY_COLORS"), ",") {
+			name, value, ok := strings.Cut(entry, "=")
+			if !ok {
+				continue
+			}
+			p, err := ParsePriority(name)
+			if err != nil {
+				continue
+			}
+			c, err := utils.ParseColor(value)
+			if err != nil {
+				continue
+			}
+			priorityColors[p] = c
+		}
+	})
+	return priorityColors
+}
+
+// DisplayColor returns the color the note is shown with: its own color, or the
+// one of its priority for notes created without one.
+func (n Note) DisplayColor() color.Attribute {
+	if n.Color != 0 {
+		return color.Attribute(n.Color)
+	}
+	return PriorityColors()[n.Priority]
+}
@@ -0,0 +1,151 @@
+package notes
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaterializeRecurring adds to its group the next instance of every repeating
+// note whose next occurrence has come, and returns how many were added. Only
+// the latest occurrence up to now is added, however many were missed.
+//
+// The rule moves to the new instance, due at that occurrence, so the previous
+// one stays as a plain note, no longer due unless it was done. Repeating notes of encrypted groups created
+// before FlagBound are repeated without asking for the passphrase, those of
+// the other encrypted groups need it as their notes are encrypted again. With
+// unlock false, those groups are left alone and reported by a *LockedError,
+// so that nothing is asked for nor encrypted again.
+func MaterializeRecurring(now time.Time, unlock bool) (int, error) {
+	groupNames, err := GroupNames()
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		added  int
+		errs   []error
+		locked LockedError
+	)
+	for _, groupName := range groupNames {
+		// look for due notes without locking the group exclusively first, as
+		// this runs on every use of keep
+		header, groupNotes, err := ReadGroup(groupName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pending := false
+		for _, n := range groupNotes {
+			if _, ok := nextOccurrence(n, now); ok {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			continue
+		}
+		if !unlock && header.Encrypted() && header.Flags&FlagBound != 0 {
+			locked.skip(groupName, fmt.Errorf("%w: %s would be encrypted again", ErrLocked, groupName))
+			continue
+		}
+
+		// a group that can't be unlocked doesn't hold back the others
+		n, err := materializeGroup(groupName, now)
+		added += n
+		if !locked.skip(groupName, err) && err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return added, locked.orNil()
+	}
+	return added, errors.Join(append(errs, locked.orNil())...)
+}
+
+func materializeGroup(groupName string, now time.Time) (int, error) {
+	g, err := openGroup(groupName, true)
+	if err != nil {
+		return 0, err
+	}
+	defer g.Close()
+
+	records, err := g.records()
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := range records {
+		at, ok := nextOccurrence(records[i], now)
+		if !ok {
+			continue
+		}
+		if _, err := g.repeat(&records[i], at, now); err != nil {
+			return added, err
+		}
+		added++
+	}
+	return added, nil
+}
+
+// nextOccurrence returns the latest occurrence of a repeating note up to now,
+// if any came after the one it is due at.
+func nextOccurrence(n Note, now time.Time) (time.Time, bool) {
+	if n.Id <= 0 || !n.Repeats() {
+		return time.Time{}, false
+	}
+	rule, err := n.RepeatRule()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	prev := n.DueTime()
+	if prev.IsZero() {
+		prev = time.UnixMilli(n.CreatedAt)
+	}
+	at := rule.Next(prev)
+	if at.IsZero() || at.After(now) {
+		return time.Time{}, false
+	}
+	for {
+		next := rule.Next(at)
+		if next.IsZero() || next.After(now) {
+			return at, true
+		}
+		at = next
+	}
+}
+
+// repeat adds the instance of the repeating note n occurring at the given
+// time to the group, moving the rule of n to it. Unless n is done, it isn't
+// due anymore, as the new instance is due in its place. n is taken as
+// stored: the sealed text of encrypted notes is copied along with its nonce
+// and tag, and only encrypted again in bound groups, where it is sealed along
+// with the UID.
+func (g *groupFile) repeat(n *Note, at time.Time, now time.Time) (Note, error) {
+	next := *n
+	next.Id = int64(g.header.SizeAlltime) + 1
+	next.CreatedAt = now.UnixMilli()
+	if err := g.rebind(&next, NewUID(next.CreatedAt)); err != nil {
+		return next, err
+	}
+	next.Due = at.UnixMilli()
+	next.DoneAt = 0
+
+	n.Repeat = [64]byte{}
+	if !n.Done() {
+		n.Due = 0
+		n.RemindBefore = 0
+	}
+	if err := g.writeNote(n.Id, n); err != nil {
+		return next, err
+	}
+	if err := g.writeNote(next.Id, &next); err != nil {
+		return next, err
+	}
+
+	g.header.Size++
+	g.header.SizeAlltime++
+	return next, g.writeHeader()
+}
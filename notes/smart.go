@@ -0,0 +1,76 @@
+package notes
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FlagSmart marks a smart group: a group holding no notes of its own but a
+// query, in place of its description, selecting notes of all the others.
+const FlagSmart uint32 = 1 << 1
+
+// Smart reports whether the header is the one of a smart group.
+func (n *NoteFileHeader) Smart() bool {
+	return n.Flags&FlagSmart != 0
+}
+
+// Query returns the query of a smart group.
+func (n *NoteFileHeader) Query() string {
+	return strings.TrimRight(string(n.Description[:]), "\x00")
+}
+
+// NewSmartGroup creates a smart group selecting the notes matching the query.
+// The query is stored as given: query.NewSmartGroup checks it first.
+func NewSmartGroup(name, query string) (NoteFileHeader, error) {
+	groupName, err := newGroup(name)
+	if err != nil {
+		return NoteFileHeader{}, err
+	}
+	header := NewNoteFileHeader(groupName.Base(), query, 0, 0)
+	if len([]rune(query)) > len(header.Description) {
+		return header, fmt.Errorf("query is longer than %v characters", len(header.Description))
+	}
+	header.Flags |= FlagSmart
+	return header, createGroup(name, header)
+}
+
+// holdsNotes fails for smart groups, which notes can't be added to.
+func (g *groupFile) holdsNotes() error {
+	if g.header.Smart() {
+		return fmt.Errorf("%s is a smart group, showing the notes of other groups matching %q", g.name, g.header.Query())
+	}
+	return nil
+}
+
+// Tags returns the tags of the note, the words of its text starting with #,
+// lowercased and without repeats.
+func (n Note) Tags() []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(n.String()) {
+		tag, ok := strings.CutPrefix(word, "#")
+		if !ok {
+			continue
+		}
+		tag = strings.ToLower(strings.TrimRightFunc(tag, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_'
+		}))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether the note has the given tag, ignoring case.
+func (n Note) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	for _, t := range n.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
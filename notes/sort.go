@@ -0,0 +1,38 @@
+package notes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SortOrder is an order notes are listed in.
+type SortOrder string
+
+const (
+	SortById       SortOrder = "id"
+	SortByPriority SortOrder = "priority" // most urgent first
+)
+
+// ParseSortOrder returns the sort order of the given name.
+func ParseSortOrder(name string) (SortOrder, error) {
+	switch order := SortOrder(name); order {
+	case SortById, SortByPriority:
+		return order, nil
+	}
+	return SortById, fmt.Errorf("unknown sort order %q, expected id or priority", name)
+}
+
+// Sort sorts notes in the given order, always listing pinned notes before the
+// rest. Notes equal in that order keep their order.
+func Sort(ns []Note, order SortOrder) {
+	sort.SliceStable(ns, func(i, j int) bool {
+		if ns[i].Pinned != ns[j].Pinned {
+			return ns[i].Pinned
+		}
+		switch order {
+		case SortByPriority:
+			return ns[i].Priority > ns[j].Priority
+		}
+		return false
+	})
+}
@@ -0,0 +1,165 @@
+package notes
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Stats are statistics about the notes of one or more groups.
+type Stats struct {
+	Groups  int `json:"groups"`  // smart groups left out
+	Total   int `json:"total"`   // notes ever added, deleted ones included
+	Live    int `json:"live"`    // notes not deleted
+	Deleted int `json:"deleted"` // records of deleted notes left in the files
+	Done    int `json:"done"`
+	// PerDay and PerWeek are how many of the live notes were added per day
+	// and per week since the oldest one. PerWeek is taken over a week at
+	// least, so a few notes of today don't make a busy week.
+	PerDay      float64        `json:"per_day"`
+	PerWeek     float64        `json:"per_week"`
+	Lengths     []LengthBucket `json:"lengths"`
+	TopTags     []TagCount     `json:"top_tags"`
+	Oldest      *NoteStat      `json:"oldest,omitempty"`
+	Newest      *NoteStat      `json:"newest,omitempty"`
+	Bytes       int64          `json:"bytes"`       // size of the files on disk
+	Reclaimable int64          `json:"reclaimable"` // bytes held by deleted notes
+}
+
+// LengthBucket counts the notes whose text is Min to Max runes long.
+type LengthBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// TagCount counts the notes holding a tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NoteStat is a note singled out by the statistics.
+type NoteStat struct {
+	Group     string    `json:"group"`
+	Id        int64     `json:"id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MaxTopTags is how many tags Stats.TopTags holds at most.
+const MaxTopTags = 10
+
+// lengthBuckets are the bounds of the buckets of Stats.Lengths, up to the
+// longest text a note holds.
+var lengthBuckets = [][2]int{{0, 19}, {20, 49}, {50, 99}, {100, 199}, {200, 300}}
+
+// GroupStats returns the statistics of the given groups taken together. The
+// notes of encrypted groups are decrypted to look at their text, so their
+// passphrase is asked for. When an encrypted group can't be unlocked, only
+// what is stored in the clear is counted, leaving its notes out of
+// Lengths and TopTags, and the group is reported by a *LockedError along
+// with the statistics.
+func GroupStats(groupNames ...string) (Stats, error) {
+	s := Stats{TopTags: []TagCount{}}
+	for _, b := range lengthBuckets {
+		s.Lengths = append(s.Lengths, LengthBucket{Min: b[0], Max: b[1]})
+	}
+	tags := map[string]int{}
+
+	var locked LockedError
+	for _, name := range groupNames {
+		if err := s.addGroup(name, tags); !locked.skip(name, err) && err != nil {
+			return s, err
+		}
+	}
+
+	for tag, count := range tags {
+		s.TopTags = append(s.TopTags, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(s.TopTags, func(i, j int) bool {
+		if s.TopTags[i].Count != s.TopTags[j].Count {
+			return s.TopTags[i].Count > s.TopTags[j].Count
+		}
+		return s.TopTags[i].Tag < s.TopTags[j].Tag
+	})
+	if len(s.TopTags) > MaxTopTags {
+		s.TopTags = s.TopTags[:MaxTopTags]
+	}
+
+	if s.Oldest != nil {
+		days := time.Since(s.Oldest.CreatedAt).Hours() / 24
+		if days < 1 {
+			days = 1
+		}
+		s.PerDay = float64(s.Live) / days
+		s.PerWeek = float64(s.Live) / max(days, 7) * 7
+	}
+	return s, locked.orNil()
+}
+
+func (s *Stats) addGroup(name string, tags map[string]int) error {
+	g, err := openGroup(name, false)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	if !g.header.Smart() {
+		s.Groups++
+	}
+	info, err := g.Stat()
+	if err != nil {
+		return err
+	}
+	s.Bytes += info.Size()
+	s.Total += int(g.header.SizeAlltime)
+
+	records, err := g.records()
+	if err != nil {
+		return err
+	}
+	// what isn't encrypted is still counted when the group is locked
+	lockErr := g.unlock()
+	if lockErr != nil && !errors.Is(lockErr, ErrLocked) {
+		return lockErr
+	}
+	for _, n := range records {
+		if n.Id <= 0 {
+			s.Deleted++
+			s.Reclaimable += int64(g.layout.note)
+			continue
+		}
+
+		s.Live++
+		if n.Done() {
+			s.Done++
+		}
+		stat := &NoteStat{Group: name, Id: n.Id, CreatedAt: time.UnixMilli(n.CreatedAt)}
+		if s.Oldest == nil || stat.CreatedAt.Before(s.Oldest.CreatedAt) {
+			s.Oldest = stat
+		}
+		if s.Newest == nil || !stat.CreatedAt.Before(s.Newest.CreatedAt) {
+			s.Newest = stat
+		}
+		if lockErr != nil {
+			continue
+		}
+
+		if err := g.open(&n); err != nil {
+			return err
+		}
+		stat.Text = n.String()
+		length := len([]rune(n.String()))
+		for i := range s.Lengths {
+			if length <= s.Lengths[i].Max {
+				s.Lengths[i].Count++
+				break
+			}
+		}
+		for _, tag := range n.Tags() {
+			tags[tag]++
+		}
+	}
+	return lockErr
+}
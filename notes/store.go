@@ -0,0 +1,333 @@
+package notes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/common"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+// openLocked opens the file at name and locks it, shared for readers and
+// exclusive for writers. The lock is released when the file is closed.
+func openLocked(name string, flag int, perm fs.FileMode, exclusive bool) (*os.File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to lock %s: %w", path.Base(name), err)
+	}
+	return f, nil
+}
+
+// StoreFile is a .kps file of the store held open and locked by LockStore.
+type StoreFile struct {
+	Name string // file name relative to the keep folder
+	File *os.File
+}
+
+// LockStore opens and locks every .kps file of the store - groups, subgroups
+// and the info file alike. While the lock is held no note can be added to or
+// deleted from them. The returned func unlocks and closes all of them.
+func LockStore(exclusive bool) ([]StoreFile, func(), error) {
+	var files []StoreFile
+
+	release := func() {
+		for _, sf := range files {
+			unlockFile(sf.File)
+			sf.File.Close()
+		}
+	}
+
+	if err := recoverJournal(); err != nil {
+		return nil, nil, err
+	}
+
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names, err := storeFiles(kfp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flag := os.O_RDONLY
+	if exclusive {
+		flag = os.O_RDWR
+	}
+
+	// always lock in the order of file names, sorted by storeFiles, so two
+	// callers can't deadlock each other
+	for _, name := range names {
+		f, err := openLocked(path.Join(kfp, name), flag, 0, exclusive)
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		files = append(files, StoreFile{Name: name, File: f})
+	}
+
+	return files, release, nil
+}
+
+// DecodeGroup parses the content of a .kps group file of any format version,
+// returning its header and every live note - deleted ones are skipped. Notes
+// of encrypted groups are returned as stored, still encrypted.
+func DecodeGroup(r io.Reader) (NoteFileHeader, []Note, error) {
+	var result []Note
+
+	nfh, version, err := readHeader(r)
+	if err != nil {
+		return nfh, nil, fmt.Errorf("%w: unable to read file header: %w", ErrCorrupt, err)
+	}
+
+	for {
+		n, err := readRecord(r, version)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nfh, nil, fmt.Errorf("%w: unable to read note: %w", ErrCorrupt, err)
+		}
+		if n.Id > 0 {
+			result = append(result, n)
+		}
+	}
+
+	return nfh, result, nil
+}
+
+// MergeNotes appends to the group the given notes it doesn't hold yet and
+// returns how many were added. A note is already held when the group has a
+// live note with the same UID, or with the same text and creation time. Added
+// notes get new ids but keep their UIDs.
+//
+// from is the header of the group the notes were decoded from. Notes of an
+// encrypted group can only be merged into the very same encrypted group.
+func MergeNotes(groupName string, from NoteFileHeader, incoming []Note) (int, error) {
+	g, err := openGroup(groupName, true)
+	if err != nil {
+		return 0, err
+	}
+	defer g.Close()
+
+	if from.Encrypted() != g.header.Encrypted() || from.Salt != g.header.Salt || from.Flags&FlagBound != g.header.Flags&FlagBound {
+		return 0, fmt.Errorf("notes of group %s are encrypted with a different key", groupName)
+	}
+
+	records, err := g.records()
+	if err != nil {
+		return 0, err
+	}
+
+	type key struct {
+		text      [300]rune
+		createdAt int64
+	}
+	var (
+		held    = make(map[key]bool, len(records))
+		heldUID = make(map[UID]bool, len(records))
+	)
+	for _, n := range records {
+		if n.Id > 0 {
+			held[key{n.Text, n.CreatedAt}] = true
+			heldUID[n.UID] = true
+		}
+	}
+
+	added := 0
+	for _, n := range incoming {
+		k := key{n.Text, n.CreatedAt}
+		if n.Id <= 0 || held[k] || heldUID[n.UID] {
+			continue
+		}
+		if n.UID.IsZero() {
+			n.UID = NewUID(n.CreatedAt)
+		}
+		held[k] = true
+		heldUID[n.UID] = true
+		n.Id = int64(g.header.SizeAlltime) + 1
+		if err := g.writeNote(n.Id, &n); err != nil {
+			return added, err
+		}
+		g.header.Size++
+		g.header.SizeAlltime++
+		added++
+	}
+
+	return added, g.writeHeader()
+}
+
+// ReadGroup returns the header and the live notes of a group as they are
+// stored, i.e. the notes of encrypted groups are still encrypted.
+func ReadGroup(groupName string) (NoteFileHeader, []Note, error) {
+	g, err := openGroup(groupName, false)
+	if err != nil {
+		return NoteFileHeader{}, nil, err
+	}
+	defer g.Close()
+
+	records, err := g.records()
+	if err != nil {
+		return g.header, nil, err
+	}
+
+	var result []Note
+	for _, n := range records {
+		if n.Id > 0 {
+			result = append(result, n)
+		}
+	}
+	return g.header, result, nil
+}
+
+// WriteGroup replaces the whole content of a group, creating it if needed. If
+// keep stops halfway, the group is rolled back to what it was. Each note is
+// written at the position of its id and the positions of missing ids are
+// marked as deleted. Notes without UID get one. Notes are otherwise
+// written as given, so notes of encrypted groups must already be encrypted
+// with the key of header.
+func WriteGroup(groupName string, header NoteFileHeader, groupNotes []Note) error {
+	if err := recoverJournal(); err != nil {
+		return err
+	}
+
+	noteFilepath, err := groupFilepath(groupName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(path.Dir(noteFilepath), 0755); err != nil {
+		return err
+	}
+	f, err := openLocked(noteFilepath, os.O_CREATE|os.O_RDWR, 0600, true)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byId := make(map[int64]Note, len(groupNotes))
+	for _, n := range groupNotes {
+		if n.Id <= 0 {
+			return fmt.Errorf("invalid note id %v", n.Id)
+		}
+		if _, ok := byId[n.Id]; ok {
+			return fmt.Errorf("duplicated note id %v", n.Id)
+		}
+		if n.UID.IsZero() {
+			n.UID = NewUID(n.CreatedAt)
+		}
+		byId[n.Id] = n
+		if n.Id > int64(header.SizeAlltime) {
+			header.SizeAlltime = uint32(n.Id)
+		}
+	}
+	header.Size = uint32(len(byId))
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, &header); err != nil {
+		return err
+	}
+	for id := int64(1); id <= int64(header.SizeAlltime); id++ {
+		n, ok := byId[id]
+		if !ok {
+			n = Note{Id: -1}
+		}
+		if err := binary.Write(&buf, binary.BigEndian, &n); err != nil {
+			return err
+		}
+	}
+
+	g := &groupFile{File: f, name: groupName}
+	return g.rewrite(buf.Bytes())
+}
+
+// GroupNames returns the names of all groups of the store, subgroups included,
+// sorted.
+func GroupNames() ([]string, error) {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := storeFiles(kfp)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range files {
+		if name != common.INFO_FILE_PATH {
+			names = append(names, groupNameOf(name))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Subgroups returns the names of the subgroups of a group, however deep,
+// sorted.
+func Subgroups(groupName string) ([]string, error) {
+	names, err := GroupNames()
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, name := range names {
+		if name != groupName && GroupName(groupName).Contains(GroupName(name)) {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// storeFiles returns the names of the .kps files of the store relative to the
+// keep folder, with slashes between directories, sorted. Files and directories
+// starting with a dot, such as the one of snapshots, aren't part of the store.
+func storeFiles(kfp string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(kfp, func(p string, d fs.DirEntry, err error) error {
+		if p == kfp && errors.Is(err, fs.ErrNotExist) {
+			return filepath.SkipAll // a store not created yet holds no groups
+		}
+		if err != nil {
+			return err
+		}
+		if p == kfp {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isKpsFile(d) {
+			return nil
+		}
+		rel, err := filepath.Rel(kfp, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dir: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
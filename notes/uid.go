@@ -0,0 +1,55 @@
+package notes
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UID identifies a note everywhere, unlike its id which is only unique within
+// its group on a single machine. It is a UUIDv7, so UIDs sort by the creation
+// time of their notes.
+type UID [16]byte
+
+// NewUID returns a new UID for a note created at the given UNIX millisecond.
+func NewUID(createdAt int64) UID {
+	var u UID
+	binary.BigEndian.PutUint64(u[:8], uint64(createdAt)<<16)
+	if _, err := rand.Read(u[6:]); err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
+	}
+	u[6] = 0x70 | u[6]&0x0f // version 7
+	u[8] = 0x80 | u[8]&0x3f // RFC 4122 variant
+	return u
+}
+
+// ParseUID parses a UID in its canonical 8-4-4-4-12 form.
+func ParseUID(s string) (UID, error) {
+	var u UID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid uid %q", s)
+	}
+	b, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil {
+		return u, fmt.Errorf("invalid uid %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func (u UID) IsZero() bool {
+	return u == UID{}
+}
+
+func (u UID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// Time returns the creation time encoded in the UID.
+func (u UID) Time() time.Time {
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(u[:8]) >> 16))
+}
@@ -0,0 +1,195 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+// Expr is a node of the syntax tree of a query.
+type Expr interface {
+	// Match reports whether the note, of the given group, matches.
+	Match(group string, n notes.Note) bool
+	// String returns the expression the way Parse reads it.
+	String() string
+}
+
+// And matches the notes matching all of its terms.
+type And []Expr
+
+func (a And) Match(group string, n notes.Note) bool {
+	for _, x := range a {
+		if !x.Match(group, n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a And) String() string {
+	terms := make([]string, len(a))
+	for i, x := range a {
+		terms[i] = nested(x)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Or matches the notes matching any of its terms.
+type Or []Expr
+
+func (o Or) Match(group string, n notes.Note) bool {
+	for _, x := range o {
+		if x.Match(group, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Or) String() string {
+	terms := make([]string, len(o))
+	for i, x := range o {
+		terms[i] = nested(x)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// Not matches the notes its term doesn't.
+type Not struct {
+	X Expr
+}
+
+func (x Not) Match(group string, n notes.Note) bool {
+	return !x.X.Match(group, n)
+}
+
+func (x Not) String() string {
+	return "-" + nested(x.X)
+}
+
+// nested returns an expression nested in another one, in parentheses when it
+// has more than a term.
+func nested(x Expr) string {
+	switch x := x.(type) {
+	case And:
+		if len(x) > 1 {
+			return "(" + x.String() + ")"
+		}
+	case Or:
+		if len(x) > 1 {
+			return "(" + x.String() + ")"
+		}
+	}
+	return x.String()
+}
+
+// Text matches the notes holding a word or a phrase, ignoring case.
+type Text string
+
+func (t Text) Match(_ string, n notes.Note) bool {
+	return strings.Contains(strings.ToLower(n.String()), strings.ToLower(string(t)))
+}
+
+func (t Text) String() string {
+	s := string(t)
+	if _, ok := flags[s]; ok || s == "OR" || strings.ContainsAny(s, " \t():\"") || strings.HasPrefix(s, "-") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// Flag matches the notes done, open or pinned.
+type Flag string
+
+var flags = map[string]func(notes.Note) bool{
+	"done":   notes.Note.Done,
+	"open":   func(n notes.Note) bool { return !n.Done() },
+	"pinned": func(n notes.Note) bool { return n.Pinned },
+}
+
+func (f Flag) Match(_ string, n notes.Note) bool {
+	return flags[string(f)](n)
+}
+
+func (f Flag) String() string {
+	return string(f)
+}
+
+// Op is the comparison of a field term.
+type Op int
+
+const (
+	Eq     Op = iota // field:value
+	Less             // field:<value
+	LessEq           // field:<=value
+	More             // field:>value
+	MoreEq           // field:>=value
+	Range            // field:low..high, both included
+)
+
+var opPrefixes = []struct {
+	prefix string
+	op     Op
+}{
+	// longest first, so "<=" isn't read as "<"
+	{"<=", LessEq},
+	{">=", MoreEq},
+	{"<", Less},
+	{">", More},
+}
+
+// Field matches the notes whose field compares to the value, as in
+// priority:>=high.
+type Field struct {
+	Name  string
+	Op    Op
+	Value string
+	High  string // upper bound of ranges
+
+	match func(group string, n notes.Note) bool
+}
+
+func (f Field) Match(group string, n notes.Note) bool {
+	return f.match(group, n)
+}
+
+func (f Field) String() string {
+	value := quoteValue(f.Value)
+	switch f.Op {
+	case Less:
+		value = "<" + value
+	case LessEq:
+		value = "<=" + value
+	case More:
+		value = ">" + value
+	case MoreEq:
+		value = ">=" + value
+	case Range:
+		value += ".." + quoteValue(f.High)
+	}
+	return f.Name + ":" + value
+}
+
+func quoteValue(s string) string {
+	if strings.ContainsAny(s, " \t()\"") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// compare reports whether x compares to the bounds as op tells.
+func (op Op) compare(x, low, high int64) bool {
+	switch op {
+	case Less:
+		return x < low
+	case LessEq:
+		return x <= low
+	case More:
+		return x > low
+	case MoreEq:
+		return x >= low
+	case Range:
+		return low <= x && x <= high
+	}
+	return x == low
+}
@@ -0,0 +1,316 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/DavidEsdrs/keep/dates"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota
+	tokPhrase           // a word starting with a quote
+	tokNot
+	tokOr
+	tokOpen
+	tokClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits a query into tokens. Quotes hold spaces within a word, as in
+// "exact phrase" or group:"my books".
+func lex(s string) ([]token, error) {
+	var tokens []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokOpen, text: "("})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokClose, text: ")"})
+			i++
+			continue
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) && rs[i+1] != ')':
+			tokens = append(tokens, token{kind: tokNot, text: "-"})
+			i++
+			continue
+		}
+
+		t := token{kind: tokWord}
+		if r == '"' {
+			t.kind = tokPhrase
+		}
+		var word strings.Builder
+		for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' {
+			if rs[i] != '"' {
+				word.WriteRune(rs[i])
+				i++
+				continue
+			}
+			end := i + 1
+			for end < len(rs) && rs[end] != '"' {
+				end++
+			}
+			if end == len(rs) {
+				return nil, fmt.Errorf("unterminated quote in %q", string(rs[i:]))
+			}
+			word.WriteString(string(rs[i+1 : end]))
+			i = end + 1
+		}
+		t.text = word.String()
+		if t.kind == tokWord && t.text == "OR" {
+			t.kind = tokOr
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
+
+// Parse parses a query, described in the package documentation. Relative
+// dates, such as created:today, are taken relative to the time of the call.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &parser{tokens: tokens, now: time.Now()}
+	x, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return x, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	now    time.Time
+}
+
+func (p *parser) done() bool {
+	return p.pos == len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// or parses terms separated by OR.
+func (p *parser) or() (Expr, error) {
+	var terms Or
+	for {
+		x, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, x)
+		if p.done() || p.peek().kind != tokOr {
+			break
+		}
+		p.pos++
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+// and parses terms up to the end of the query, of the parentheses or to the
+// next OR.
+func (p *parser) and() (Expr, error) {
+	var terms And
+	for !p.done() && p.peek().kind != tokOr && p.peek().kind != tokClose {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, x)
+	}
+	switch {
+	case len(terms) == 0 && p.done():
+		return nil, fmt.Errorf("missing term at the end of the query")
+	case len(terms) == 0:
+		return nil, fmt.Errorf("missing term before %q", p.peek().text)
+	case len(terms) == 1:
+		return terms[0], nil
+	}
+	return terms, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	t := p.peek()
+	p.pos++
+	switch t.kind {
+	case tokNot:
+		if p.done() {
+			return nil, fmt.Errorf("missing term after -")
+		}
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: x}, nil
+	case tokOpen:
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.peek().kind != tokClose {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return x, nil
+	case tokPhrase:
+		return Text(t.text), nil
+	}
+	return p.term(t.text)
+}
+
+// term parses a word, which is a field term when it holds a colon.
+func (p *parser) term(word string) (Expr, error) {
+	name, value, ok := strings.Cut(word, ":")
+	if !ok {
+		if _, ok := flags[word]; ok {
+			return Flag(word), nil
+		}
+		return Text(word), nil
+	}
+
+	f := Field{Name: strings.ToLower(name), Value: value}
+	for _, o := range opPrefixes {
+		if rest, ok := strings.CutPrefix(value, o.prefix); ok {
+			f.Op, f.Value = o.op, rest
+			break
+		}
+	}
+	if f.Op == Eq {
+		if low, high, ok := strings.Cut(value, ".."); ok {
+			f.Op, f.Value, f.High = Range, low, high
+		}
+	}
+	if f.Value == "" || f.Op == Range && f.High == "" {
+		return nil, fmt.Errorf("missing value in %q", word)
+	}
+
+	var err error
+	switch f.Name {
+	case "group", "tag", "color":
+		err = f.compileEq()
+	case "priority":
+		err = f.compileOrdered(func(s string) (int64, error) {
+			priority, err := notes.ParsePriority(s)
+			return int64(priority), err
+		}, func(_ string, n notes.Note) (int64, bool) {
+			return int64(n.Priority), true
+		})
+	case "id":
+		err = f.compileOrdered(func(s string) (int64, error) {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid id %q", s)
+			}
+			return id, nil
+		}, func(_ string, n notes.Note) (int64, bool) {
+			return n.Id, true
+		})
+	case "created":
+		err = f.compileOrdered(p.day, func(_ string, n notes.Note) (int64, bool) {
+			return dayOf(time.UnixMilli(n.CreatedAt).In(p.now.Location())), true
+		})
+	case "due":
+		err = f.compileOrdered(p.day, func(_ string, n notes.Note) (int64, bool) {
+			if n.Due == 0 {
+				return 0, false
+			}
+			return dayOf(n.DueTime().In(p.now.Location())), true
+		})
+	default:
+		return nil, fmt.Errorf("unknown field %q in %q, expected group, tag, color, priority, id, created or due", name, word)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid term %q: %w", word, err)
+	}
+	return f, nil
+}
+
+// compileEq sets the match of the fields that can only be equal to a value.
+func (f *Field) compileEq() error {
+	if f.Op != Eq {
+		return fmt.Errorf("%s can't be compared", f.Name)
+	}
+	value := f.Value
+	switch f.Name {
+	case "group":
+		f.match = func(group string, _ notes.Note) bool {
+			return notes.GroupName(value).Contains(notes.GroupName(group))
+		}
+	case "tag":
+		f.match = func(_ string, n notes.Note) bool { return n.HasTag(value) }
+	case "color":
+		c, err := utils.ParseColor(value)
+		if err != nil {
+			return err
+		}
+		f.match = func(_ string, n notes.Note) bool { return n.DisplayColor() == c }
+	}
+	return nil
+}
+
+// compileOrdered sets the match of a field whose values are ordered. key
+// returns the value of the note, or false when the note has none.
+func (f *Field) compileOrdered(parse func(string) (int64, error), key func(string, notes.Note) (int64, bool)) error {
+	low, err := parse(f.Value)
+	if err != nil {
+		return err
+	}
+	var high int64
+	if f.Op == Range {
+		if high, err = parse(f.High); err != nil {
+			return err
+		}
+	}
+	op := f.Op
+	f.match = func(group string, n notes.Note) bool {
+		x, ok := key(group, n)
+		return ok && op.compare(x, low, high)
+	}
+	return nil
+}
+
+// day parses a date as the day it falls on.
+func (p *parser) day(s string) (int64, error) {
+	t, err := dates.Parse(s, p.now)
+	if err != nil {
+		return 0, err
+	}
+	return dayOf(t), nil
+}
+
+// dayOf returns the day of t as yyyymmdd, which compares as days do.
+func dayOf(t time.Time) int64 {
+	y, m, d := t.Date()
+	return int64(y)*10000 + int64(m)*100 + int64(d)
+}
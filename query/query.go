@@ -0,0 +1,55 @@
+// Package query parses and evaluates the queries selecting notes, as in
+// `group:books tag:fav created:>2026-01-01 "exact phrase" -excluded`. They are
+// what read, all, search, smart groups and the API filter notes with.
+//
+// A query is a sequence of terms a note must all match:
+//
+//   - a word or a "quoted phrase", matching the notes holding it, ignoring
+//     case
+//   - done, open and pinned, matching the notes done, not done or pinned.
+//     Quote them to look for the word itself
+//   - group:<group>, the notes of the group or of its subgroups
+//   - tag:<tag>, the notes tagged #<tag>
+//   - color:<color>, the notes shown in that color
+//   - priority:<priority>, id:<id>, created:<date> and due:<date>. These can
+//     be compared, as in priority:>=high, id:<10 or created:>2026-01-01, or
+//     given a range, as in id:10..20. Dates are compared by day and can be
+//     any date dates.Parse understands, quoted if it has spaces
+//
+// A term starting with - matches the notes the term doesn't, terms separated
+// by OR match the notes matching either and parentheses group terms, as in
+// `tag:work (priority:urgent OR due:today) -done`.
+package query
+
+import (
+	"fmt"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+// NewSmartGroup creates a smart group selecting the notes matching the query,
+// failing if the query is invalid.
+func NewSmartGroup(name, q string) (notes.NoteFileHeader, error) {
+	if _, err := Parse(q); err != nil {
+		return notes.NoteFileHeader{}, err
+	}
+	return notes.NewSmartGroup(name, q)
+}
+
+// ReadSmartGroup returns the notes of all other groups matching the query of a
+// smart group, keyed by group name. Like notes.Collect, it skips the encrypted
+// groups that can't be unlocked with a *notes.LockedError.
+func ReadSmartGroup(name string) (map[string][]notes.Note, error) {
+	header, err := notes.GetGroupHeader(name)
+	if err != nil {
+		return nil, err
+	}
+	if !header.Smart() {
+		return nil, fmt.Errorf("group %s isn't a smart group", name)
+	}
+	q, err := Parse(header.Query())
+	if err != nil {
+		return nil, fmt.Errorf("invalid query of smart group %s: %w", name, err)
+	}
+	return notes.Collect(q.Match)
+}
@@ -0,0 +1,166 @@
+package query_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/query"
+	"github.com/DavidEsdrs/keep/utils"
+	"github.com/fatih/color"
+)
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]string{
+		`group:books tag:fav`:                             `group:books tag:fav`,
+		`created:>2026-01-01 "exact phrase"`:              `created:>2026-01-01 "exact phrase"`,
+		`-excluded color:red id:10..20`:                   `-excluded color:red id:10..20`,
+		`tag:work (priority:urgent OR due:<=today) -done`: `tag:work (priority:urgent OR due:<=today) -done`,
+		`-(a b)`:                  `-(a b)`,
+		`a OR b c`:                `a OR (b c)`,
+		`group:"my books" "done"`: `group:"my books" "done"`,
+		`well-known`:              `well-known`,
+	} {
+		x, err := query.Parse(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if x.String() != want {
+			t.Fatalf("%q: expected %q, got %q", s, want, x.String())
+		}
+	}
+
+	for _, s := range []string{
+		"", "  ", `"open`, "(a", "a)", "a OR", "OR a", "()",
+		"colour:red", "color:plaid", "tag:>a", "id:x", "id:1..", "priority:>=huge",
+		"created:someday", "group:",
+	} {
+		if _, err := query.Parse(s); err == nil {
+			t.Fatalf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	created := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local).UnixMilli()
+	n := notes.NewNote(12, "Read Crafting Interpreters #fav #books", 0, created)
+	n.Priority = notes.PriorityHigh
+	n.Due = time.Date(2026, 4, 1, 9, 0, 0, 0, time.Local).UnixMilli()
+
+	for s, want := range map[string]bool{
+		"group:books":                    true,
+		"group:books/novels":             false,
+		"tag:fav":                        true,
+		"tag:FAV":                        true,
+		"tag:fa":                         false,
+		`"crafting interpreters"`:        true,
+		`"interpreters crafting"`:        false,
+		"-crafting":                      false,
+		"-novel":                         true,
+		"color:yellow":                   true,
+		"color:red":                      false,
+		"id:10..20":                      true,
+		"id:<12":                         false,
+		"id:<=12":                        true,
+		"priority:high":                  true,
+		"priority:>=normal":              true,
+		"priority:urgent":                false,
+		"created:2026-03-10":             true,
+		"created:>2026-03-10":            false,
+		"created:>=2026-03-10":           true,
+		"created:2026-03-01..2026-03-31": true,
+		"due:<2026-04-02":                true,
+		"open":                           true,
+		"done OR pinned":                 false,
+		"open -(done OR pinned)":         true,
+		"group:books tag:fav created:>2026-01-01 \"crafting\" -excluded id:10..20": true,
+	} {
+		x, err := query.Parse(s)
+		if err != nil {
+			t.Fatalf("%q: %v", s, err)
+		}
+		if got := x.Match("books", n); got != want {
+			t.Fatalf("%q: expected %v, got %v", s, want, got)
+		}
+	}
+
+	if x, _ := query.Parse("due:<2030-01-01"); x.Match("books", notes.NewNote(1, "no due date", color.FgRed, created)) {
+		t.Fatal("expected notes without due date not to match due:")
+	}
+}
+
+func TestSmartGroups(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"work", "work/projectA", "home"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	urgent := notes.NoteOptions{Priority: notes.PriorityUrgent}
+	for _, n := range []struct{ group, text string }{
+		{"work", "Fix the build #work"},
+		{"work", "Deploy the fix #Work, #release"},
+		{"work/projectA", "Review the design #work"},
+		{"home", "Pay rent #home"},
+	} {
+		if _, err := notes.AddNoteWith(n.group, n.text, urgent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := notes.MarkDone("work", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := query.NewSmartGroup("bad", "colour:red"); err == nil {
+		t.Fatal("expected an invalid query to fail")
+	}
+	if _, err := query.NewSmartGroup("urgent-work", "tag:work priority:urgent -done"); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := query.ReadSmartGroup("urgent-work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || len(found["work"]) != 1 || found["work"][0].Id != 2 || len(found["work/projectA"]) != 1 {
+		t.Fatalf("unexpected notes %v", found)
+	}
+
+	// an encrypted group whose passphrase is unknown is left out
+	if _, err := notes.NewEncryptedNoteFile("diary", "", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := notes.AddNoteWith("diary", "Dear diary #work", urgent); err != nil {
+		t.Fatal(err)
+	}
+	header, stored, err := notes.ReadGroup("diary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Salt[0] ^= 0xff
+	if err := notes.WriteGroup("diary", header, stored); err != nil {
+		t.Fatal(err)
+	}
+	found, err = query.ReadSmartGroup("urgent-work")
+	var locked *notes.LockedError
+	if !errors.As(err, &locked) || len(locked.Groups) != 1 || locked.Groups[0] != "diary" {
+		t.Fatalf("expected diary to be skipped, got %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("unexpected notes %v", found)
+	}
+
+	if _, err := query.ReadSmartGroup("work"); err == nil {
+		t.Fatal("expected reading a group as a smart group to fail")
+	}
+}
@@ -0,0 +1,218 @@
+// Package recur parses the recurrence rules of repeating notes, either a named
+// interval such as "daily" or a cron expression such as "0 9 * * MON".
+package recur
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/dates"
+)
+
+// Rule tells when a repeating note occurs again.
+type Rule interface {
+	// Next returns the first occurrence strictly after prev, or the zero time
+	// if there is none.
+	Next(prev time.Time) time.Time
+	// String returns the rule as it is parsed by Parse.
+	String() string
+}
+
+// Parse parses a recurrence rule, one of:
+//
+//   - "daily", "weekly", "monthly" or "yearly", repeating at the same time of
+//     the day as the previous occurrence
+//   - "every <duration>", as in "every 2d" or "every 12 hours"
+//   - a cron expression of five fields: minute, hour, day of month, month and
+//     day of week, as in "0 9 * * MON-FRI" or "*/30 8-18 * * *"
+func Parse(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "daily":
+		return interval{name: "daily", days: 1}, nil
+	case "weekly":
+		return interval{name: "weekly", days: 7}, nil
+	case "monthly":
+		return interval{name: "monthly", months: 1}, nil
+	case "yearly":
+		return interval{name: "yearly", months: 12}, nil
+	}
+
+	if rest, ok := strings.CutPrefix(strings.ToLower(s), "every "); ok {
+		d, err := dates.ParseDuration(rest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", s, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("invalid rule %q: notes can't repeat more than once a minute", s)
+		}
+		return interval{name: "every " + dates.FormatDuration(d), every: d}, nil
+	}
+
+	return parseCron(s)
+}
+
+type interval struct {
+	name   string
+	days   int
+	months int
+	every  time.Duration
+}
+
+func (i interval) Next(prev time.Time) time.Time {
+	if i.every != 0 {
+		return prev.Add(i.every)
+	}
+	return prev.AddDate(0, i.months, i.days)
+}
+
+func (i interval) String() string {
+	return i.name
+}
+
+// cron is a parsed cron expression, each field as the set of values it
+// matches.
+type cron struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDom bool // whether day of month is "*"
+	anyDow bool
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+func parseCron(expr string) (Rule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid rule %q: expected daily, weekly, monthly, yearly, every <duration> or a cron expression", expr)
+	}
+
+	c := cron{expr: strings.Join(fields, " ")}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute of %q: %w", expr, err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour of %q: %w", expr, err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month of %q: %w", expr, err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid month of %q: %w", expr, err)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week of %q: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is sunday too
+	}
+	c.anyDom = fields[2] == "*"
+	c.anyDow = fields[4] == "*"
+	return c, nil
+}
+
+// parseField parses a comma separated list of values, ranges ("1-5") and steps
+// ("*/15", "8-18/2") into a bit set.
+func parseField(field string, min, max int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		every := 1
+		if hasStep {
+			var err error
+			if every, err = strconv.Atoi(step); err != nil || every <= 0 {
+				return 0, fmt.Errorf("invalid step %q", step)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(from, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(to, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %v-%v", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += every {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseValue(s string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+func (c cron) Next(prev time.Time) time.Time {
+	t := prev.Truncate(time.Minute).Add(time.Minute)
+
+	// rules such as "0 0 30 2 *" never occur, so give up after a few years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay follows cron: when both day of month and day of week are given, a
+// day matching either of them matches.
+func (c cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.anyDom:
+		return dow
+	case c.anyDow:
+		return dom
+	}
+	return dom || dow
+}
+
+func (c cron) String() string {
+	return c.expr
+}
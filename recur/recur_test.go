@@ -0,0 +1,56 @@
+package recur_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/recur"
+)
+
+// a thursday
+var prev = time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+func TestNext(t *testing.T) {
+	for rule, want := range map[string]time.Time{
+		"daily":           time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		"weekly":          time.Date(2026, 10, 22, 9, 0, 0, 0, time.UTC),
+		"monthly":         time.Date(2026, 11, 15, 9, 0, 0, 0, time.UTC),
+		"every 2d":        time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC),
+		"every 12 hours":  time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC),
+		"0 9 * * MON":     time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		"0 9 * * mon-fri": time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		"*/30 8-18 * * *": time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC),
+		"0 0 1 * *":       time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+		"0 8 1 jan *":     time.Date(2027, 1, 1, 8, 0, 0, 0, time.UTC),
+		"0 9 13 * 5":      time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC),
+		"0 9 * * 7":       time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC),
+	} {
+		r, err := recur.Parse(rule)
+		if err != nil {
+			t.Fatalf("%q: %v", rule, err)
+		}
+		if got := r.Next(prev); !got.Equal(want) {
+			t.Fatalf("%q: expected %v, got %v", rule, want, got)
+		}
+		again, err := recur.Parse(r.String())
+		if err != nil || again.Next(prev) != r.Next(prev) {
+			t.Fatalf("%q doesn't round trip: %q", rule, r.String())
+		}
+	}
+
+	never, err := recur.Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !never.Next(prev).IsZero() {
+		t.Fatal("expected no occurrence on february 30")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rule := range []string{"", "sometimes", "every 10s", "60 * * * *", "* * * *", "0 9 * * FUNDAY", "*/0 * * * *"} {
+		if _, err := recur.Parse(rule); err == nil {
+			t.Fatalf("%q: expected an error", rule)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Package server exposes the notes through a local HTTP/JSON API, so editor
+// plugins and dashboards can use keep without running it.
+//
+// Every endpoint but /openapi.json requires the "Authorization: Bearer <token>"
+// header. Errors are returned as {"error": "message"} with a matching status
+// code. The API is described by the OpenAPI document in openapi.json.
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/keyring"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/query"
+)
+
+//go:embed openapi.json
+var openAPI []byte
+
+type Server struct {
+	token string
+	mux   *http.ServeMux
+}
+
+// New returns a server accepting requests bearing the given token.
+func New(token string) *Server {
+	s := &Server{token: token, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /openapi.json", s.openAPI)
+
+	s.handle("GET /groups", s.listGroups)
+	s.handle("POST /groups", s.createGroup)
+	s.handle("DELETE /groups/{group}", s.deleteGroup)
+
+	s.handle("GET /groups/{group}/notes", s.listNotes)
+	s.handle("POST /groups/{group}/notes", s.addNote)
+	s.handle("GET /groups/{group}/notes/{note}", s.getNote)
+	s.handle("PUT /groups/{group}/notes/{note}", s.editNote)
+	s.handle("DELETE /groups/{group}/notes/{note}", s.deleteNote)
+
+	s.handle("GET /search", s.search)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// apiError is an error with the status code it is reported with.
+type apiError struct {
+	status int
+	msg    string
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func errorf(status int, format string, args ...any) error {
+	return &apiError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// handle registers a handler behind the token check, reporting its error as
+// JSON.
+func (s *Server) handle(pattern string, h func(w http.ResponseWriter, r *http.Request) error) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, errorf(http.StatusUnauthorized, "missing or invalid token"))
+			return
+		}
+		if err := h(w, r); err != nil {
+			writeError(w, err)
+		}
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	want := "Bearer " + s.token
+	got := r.Header.Get("Authorization")
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, keyring.ErrNoPassphrase):
+		apiErr = &apiError{status: http.StatusForbidden, msg: err.Error()}
+	case errors.Is(err, notes.ErrGroupNotFound), errors.Is(err, notes.ErrNoteNotFound), errors.Is(err, notes.ErrNoteDeleted):
+		apiErr = &apiError{status: http.StatusNotFound, msg: err.Error()}
+	default:
+		apiErr = &apiError{status: http.StatusInternalServerError, msg: err.Error()}
+	}
+	writeJSON(w, apiErr.status, map[string]string{"error": apiErr.msg})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+type Group struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Size        uint32    `json:"size"`
+	Encrypted   bool      `json:"encrypted"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Note struct {
+	Id        int64     `json:"id"`
+	UID       string    `json:"uid"`
+	Text      string    `json:"text"`
+	Color     int32     `json:"color"`
+	Pinned    bool      `json:"pinned"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func groupJSON(name string, h notes.NoteFileHeader) Group {
+	return Group{
+		Name:        name,
+		Description: trimRunes(h.Description[:]),
+		Size:        h.Size,
+		Encrypted:   h.Encrypted(),
+		CreatedAt:   time.UnixMilli(h.CreatedAt).UTC(),
+	}
+}
+
+func noteJSON(n notes.Note) Note {
+	return Note{
+		Id:        n.Id,
+		UID:       n.UID.String(),
+		Text:      n.String(),
+		Color:     int32(n.DisplayColor()),
+		Pinned:    n.Pinned,
+		CreatedAt: time.UnixMilli(n.CreatedAt).UTC(),
+	}
+}
+
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request) error {
+	names, err := notes.GroupNames()
+	if err != nil {
+		return err
+	}
+	groups := []Group{}
+	for _, name := range names {
+		header, err := notes.GetGroupHeader(name)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, groupJSON(name, header))
+	}
+	writeJSON(w, http.StatusOK, groups)
+	return nil
+}
+
+func (s *Server) createGroup(w http.ResponseWriter, r *http.Request) error {
+	var body struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := readJSON(w, r, &body); err != nil {
+		return err
+	}
+	if body.Name == "" {
+		return errorf(http.StatusBadRequest, "group name is required")
+	}
+	if _, err := notes.NewGroupName(body.Name); err != nil {
+		return errorf(http.StatusBadRequest, "%v", err)
+	}
+	if exists, err := groupExists(body.Name); err != nil {
+		return err
+	} else if exists {
+		return errorf(http.StatusConflict, "group %s already exists", body.Name)
+	}
+	header, err := notes.NewNoteFile(body.Name, body.Description)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, groupJSON(body.Name, header))
+	return nil
+}
+
+func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request) error {
+	group, err := existingGroup(r)
+	if err != nil {
+		return err
+	}
+	if r.URL.Query().Get("recursive") == "true" {
+		err = notes.DeleteGroupTree(group)
+	} else {
+		err = notes.DeleteGroup(group)
+	}
+	if errors.Is(err, notes.ErrHasSubgroups) {
+		return errorf(http.StatusConflict, "%v, delete them too with recursive=true", err)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) listNotes(w http.ResponseWriter, r *http.Request) error {
+	group, err := existingGroup(r)
+	if err != nil {
+		return err
+	}
+	var q query.Expr
+	if raw := r.URL.Query().Get("q"); raw != "" {
+		if q, err = query.Parse(raw); err != nil {
+			return errorf(http.StatusBadRequest, "invalid query: %v", err)
+		}
+	}
+	ch, err := notes.ReadAllNotes(group)
+	if err != nil {
+		return err
+	}
+	var groupNotes []notes.Note
+	for n := range ch {
+		if q == nil || q.Match(group, n) {
+			groupNotes = append(groupNotes, n)
+		}
+	}
+	notes.Sort(groupNotes, notes.SortById)
+	result := []Note{}
+	for _, n := range groupNotes {
+		result = append(result, noteJSON(n))
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *Server) addNote(w http.ResponseWriter, r *http.Request) error {
+	group, err := existingGroup(r)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := readJSON(w, r, &body); err != nil {
+		return err
+	}
+	if body.Text == "" {
+		return errorf(http.StatusBadRequest, "note text is required")
+	}
+	note, err := notes.AddNoteWith(group, body.Text, notes.NoteOptions{})
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, noteJSON(note))
+	return nil
+}
+
+func (s *Server) getNote(w http.ResponseWriter, r *http.Request) error {
+	group, id, err := existingNote(r)
+	if err != nil {
+		return err
+	}
+	note, err := notes.GetNoteById(group, id)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, noteJSON(note))
+	return nil
+}
+
+func (s *Server) editNote(w http.ResponseWriter, r *http.Request) error {
+	group, id, err := existingNote(r)
+	if err != nil {
+		return err
+	}
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := readJSON(w, r, &body); err != nil {
+		return err
+	}
+	if body.Text == "" {
+		return errorf(http.StatusBadRequest, "note text is required")
+	}
+	note, err := notes.EditNote(group, id, body.Text)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, noteJSON(note))
+	return nil
+}
+
+func (s *Server) deleteNote(w http.ResponseWriter, r *http.Request) error {
+	group, id, err := existingNote(r)
+	if err != nil {
+		return err
+	}
+	if err := notes.DeleteNoteById(group, id); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) error {
+	term := r.URL.Query().Get("q")
+	if term == "" {
+		return errorf(http.StatusBadRequest, "query parameter q is required")
+	}
+	q, err := query.Parse(term)
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid query: %v", err)
+	}
+	found, err := notes.Collect(q.Match)
+	var locked *notes.LockedError
+	if errors.As(err, &locked) {
+		// the notes of the other groups are still worth answering with
+		w.Header().Set("Keep-Locked-Groups", strings.Join(locked.Groups, ","))
+	} else if err != nil {
+		return err
+	}
+
+	type result struct {
+		Group string `json:"group"`
+		Note
+	}
+	results := []result{}
+	for group, groupNotes := range found {
+		for _, n := range groupNotes {
+			results = append(results, result{Group: group, Note: noteJSON(n)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Group != results[j].Group {
+			return results[i].Group < results[j].Group
+		}
+		if results[i].Pinned != results[j].Pinned {
+			return results[i].Pinned
+		}
+		return results[i].Id < results[j].Id
+	})
+	writeJSON(w, http.StatusOK, results)
+	return nil
+}
+
+func groupExists(name string) (bool, error) {
+	names, err := notes.GroupNames()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(names, name), nil
+}
+
+func existingGroup(r *http.Request) (string, error) {
+	group := r.PathValue("group")
+	exists, err := groupExists(group)
+	if err != nil {
+		return group, err
+	}
+	if !exists {
+		return group, errorf(http.StatusNotFound, "group %s not found", group)
+	}
+	return group, nil
+}
+
+// existingNote returns the group and the id of the live note a request refers
+// to, by id or by UID.
+func existingNote(r *http.Request) (string, int64, error) {
+	group, err := existingGroup(r)
+	if err != nil {
+		return group, 0, err
+	}
+	ref := r.PathValue("note")
+	id, err := notes.ResolveNoteRef(group, ref)
+	if err != nil {
+		return group, 0, errorf(http.StatusNotFound, "note %s not found", ref)
+	}
+	_, err = notes.GetNoteById(group, id)
+	if errors.Is(err, notes.ErrNoteNotFound) || errors.Is(err, notes.ErrNoteDeleted) {
+		return group, id, errorf(http.StatusNotFound, "note %s not found", ref)
+	}
+	return group, id, err
+}
+
+func trimRunes(r []rune) string {
+	for len(r) > 0 && r[len(r)-1] == 0 {
+		r = r[:len(r)-1]
+	}
+	return string(r)
+}
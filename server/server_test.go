@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/server"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+const token = "secret"
+
+func setup(t *testing.T) *httptest.Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.New(token))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// do sends a request with the token and decodes the response into out, unless
+// out is nil
+func do(t *testing.T, ts *httptest.Server, method, path, body string, want int, out any) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		var e map[string]string
+		json.NewDecoder(resp.Body).Decode(&e)
+		t.Fatalf("%s %s: expected status %v, got %v %v", method, path, want, resp.StatusCode, e)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	ts := setup(t)
+
+	resp, err := http.Get(ts.URL + "/groups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %v", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var doc map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || doc["openapi"] == nil {
+		t.Fatalf("unexpected openapi document %v", resp.StatusCode)
+	}
+}
+
+func TestNotes(t *testing.T) {
+	ts := setup(t)
+
+	var group server.Group
+	do(t, ts, "POST", "/groups", `{"name": "books", "description": "books to read"}`, http.StatusCreated, &group)
+	if group.Name != "books" || group.Description != "books to read" {
+		t.Fatalf("unexpected group %+v", group)
+	}
+	do(t, ts, "POST", "/groups", `{"name": "books"}`, http.StatusConflict, nil)
+	do(t, ts, "POST", "/groups", `{"name": "../books"}`, http.StatusBadRequest, nil)
+	do(t, ts, "POST", "/groups", `{"name": "books/read"}`, http.StatusCreated, nil)
+	do(t, ts, "GET", "/groups/books%2Fread/notes", "", http.StatusOK, nil)
+	do(t, ts, "DELETE", "/groups/books", "", http.StatusConflict, nil)
+	do(t, ts, "DELETE", "/groups/books%2Fread", "", http.StatusNoContent, nil)
+
+	var first, second server.Note
+	do(t, ts, "POST", "/groups/books/notes", `{"text": "Crafting Interpreters"}`, http.StatusCreated, &first)
+	do(t, ts, "POST", "/groups/books/notes", `{"text": "The Go Programming Language"}`, http.StatusCreated, &second)
+	if first.Id != 1 || second.Id != 2 || first.UID == "" {
+		t.Fatalf("unexpected notes %+v %+v", first, second)
+	}
+
+	var edited server.Note
+	do(t, ts, "PUT", "/groups/books/notes/"+first.UID, `{"text": "Programming Language Pragmatics"}`, http.StatusOK, &edited)
+	if edited.Id != 1 || edited.UID != first.UID || edited.Text != "Programming Language Pragmatics" {
+		t.Fatalf("unexpected edited note %+v", edited)
+	}
+
+	do(t, ts, "DELETE", "/groups/books/notes/2", "", http.StatusNoContent, nil)
+	do(t, ts, "GET", "/groups/books/notes/2", "", http.StatusNotFound, nil)
+	do(t, ts, "GET", "/groups/movies/notes", "", http.StatusNotFound, nil)
+
+	var list []server.Note
+	do(t, ts, "GET", "/groups/books/notes", "", http.StatusOK, &list)
+	if len(list) != 1 || list[0].Text != "Programming Language Pragmatics" {
+		t.Fatalf("unexpected notes %+v", list)
+	}
+
+	var found []struct {
+		Group string `json:"group"`
+		server.Note
+	}
+	do(t, ts, "GET", "/search?q=pragmatics", "", http.StatusOK, &found)
+	if len(found) != 1 || found[0].Group != "books" || found[0].Id != 1 {
+		t.Fatalf("unexpected search results %+v", found)
+	}
+	do(t, ts, "GET", "/search?q=group:books+-%22language+pragmatics%22", "", http.StatusOK, &found)
+	if len(found) != 0 {
+		t.Fatalf("unexpected search results %+v", found)
+	}
+	do(t, ts, "GET", "/search?q=id:x", "", http.StatusBadRequest, nil)
+
+	do(t, ts, "GET", "/groups/books/notes?q=id:%3E1", "", http.StatusOK, &list)
+	if len(list) != 0 {
+		t.Fatalf("unexpected notes %+v", list)
+	}
+
+	do(t, ts, "DELETE", "/groups/books", "", http.StatusNoContent, nil)
+	var groups []server.Group
+	do(t, ts, "GET", "/groups", "", http.StatusOK, &groups)
+	if len(groups) != 0 {
+		t.Fatalf("expected no groups, got %+v", groups)
+	}
+}
@@ -0,0 +1,354 @@
+// Package snapshots keeps rolling backups of the store inside the keep folder,
+// so notes lost to a mistaken delete can be brought back.
+//
+// A snapshot of a group is taken before any of its notes, or the group itself,
+// is deleted, and a snapshot of the whole store is taken on the first use of
+// each day. Old snapshots are pruned following a retention Policy.
+package snapshots
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/backup"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+const (
+	dirName     = ".snapshots"
+	extension   = ".tar.gz"
+	stampLayout = "20060102T150405.000Z"
+)
+
+type Kind string
+
+const (
+	Daily     Kind = "daily"
+	PreDelete Kind = "pre-delete"
+)
+
+type Snapshot struct {
+	Name      string
+	Kind      Kind
+	Group     string // group saved by a pre-delete snapshot
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Dir returns the folder where snapshots are stored.
+func Dir() (string, error) {
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(kfp, dirName), nil
+}
+
+// Take saves a snapshot of the given kind. Pre-delete snapshots hold only the
+// given group, daily ones the whole store.
+func Take(kind Kind, group string) (Snapshot, error) {
+	dir, err := Dir()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return Snapshot{}, fmt.Errorf("unable to create snapshots dir: %w", err)
+	}
+
+	snap := Snapshot{
+		Kind:      kind,
+		Group:     group,
+		CreatedAt: time.Now().UTC(),
+	}
+	snap.Name = snapshotName(snap)
+
+	f, err := os.OpenFile(path.Join(dir, snap.Name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return snap, fmt.Errorf("unable to create snapshot: %w", err)
+	}
+	defer f.Close()
+
+	if kind == PreDelete {
+		_, err = backup.CreateOnly(f, group+".kps")
+	} else {
+		_, err = backup.Create(f)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return snap, fmt.Errorf("unable to create snapshot: %w", err)
+	}
+
+	if info, err := f.Stat(); err == nil {
+		snap.Size = info.Size()
+	}
+
+	return snap, nil
+}
+
+// Warn is told of the failures that don't stop keep, such as failing to prune
+// old snapshots. They are dropped unless it is set.
+var Warn func(err error)
+
+// BeforeDestroy snapshots a group that is about to lose notes and prunes old
+// snapshots. It is meant to be set as notes.BeforeDestroy. Only failing to
+// take the snapshot keeps the notes from being deleted.
+func BeforeDestroy(group string) error {
+	if _, err := Take(PreDelete, group); err != nil {
+		return err
+	}
+	prune()
+	return nil
+}
+
+// TakeDaily snapshots the whole store unless it was already done today, and
+// reports whether a snapshot was taken. An empty store isn't saved.
+func TakeDaily() (bool, error) {
+	groups, err := notes.GetGroups()
+	if err != nil || len(groups) == 0 {
+		return false, err
+	}
+
+	snaps, err := List()
+	if err != nil {
+		return false, err
+	}
+
+	today := time.Now().Local().Format(time.DateOnly)
+	for _, s := range snaps {
+		if s.Kind == Daily && s.CreatedAt.Local().Format(time.DateOnly) == today {
+			return false, nil
+		}
+	}
+
+	if _, err := Take(Daily, ""); err != nil {
+		return false, err
+	}
+	prune()
+	return true, nil
+}
+
+// List returns all snapshots, newest first.
+func List() ([]Snapshot, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read snapshots dir: %w", err)
+	}
+
+	var snaps []Snapshot
+	for _, e := range entries {
+		snap, ok := parseName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			snap.Size = info.Size()
+		}
+		snaps = append(snaps, snap)
+	}
+
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+
+	return snaps, nil
+}
+
+// Restore applies the named snapshot to the store. Replacing from a pre-delete
+// snapshot only replaces the group it holds.
+func Restore(name string, mode backup.Mode) error {
+	if _, ok := parseName(name); !ok || name != path.Base(name) {
+		return fmt.Errorf("%q is not a snapshot", name)
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("no snapshot with given name")
+	}
+	defer f.Close()
+
+	_, err = backup.Restore(f, mode)
+	return err
+}
+
+// Prune removes the snapshots not kept by the configured policy and returns
+// them.
+func Prune() ([]Snapshot, error) {
+	policy, err := PolicyFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	snaps, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := policy.Expired(snaps)
+	for _, s := range removed {
+		if err := os.Remove(path.Join(dir, s.Name)); err != nil {
+			return nil, fmt.Errorf("unable to remove snapshot %s: %w", s.Name, err)
+		}
+	}
+	return removed, nil
+}
+
+// prune prunes the snapshots after one was taken, warning when it fails.
+func prune() {
+	if _, err := Prune(); err != nil && Warn != nil {
+		Warn(fmt.Errorf("unable to prune snapshots: %w", err))
+	}
+}
+
+func snapshotName(s Snapshot) string {
+	name := s.CreatedAt.Format(stampLayout) + "-" + string(s.Kind)
+	if s.Group != "" {
+		// subgroups are named after their parents, with slashes
+		name += "-" + url.PathEscape(s.Group)
+	}
+	return name + extension
+}
+
+func parseName(name string) (Snapshot, bool) {
+	var snap Snapshot
+
+	rest, ok := strings.CutSuffix(name, extension)
+	if !ok {
+		return snap, false
+	}
+	stamp, rest, ok := strings.Cut(rest, "-")
+	if !ok {
+		return snap, false
+	}
+	createdAt, err := time.Parse(stampLayout, stamp)
+	if err != nil {
+		return snap, false
+	}
+
+	switch {
+	case rest == string(Daily):
+		snap.Kind = Daily
+	case strings.HasPrefix(rest, string(PreDelete)+"-"):
+		snap.Kind = PreDelete
+		snap.Group = strings.TrimPrefix(rest, string(PreDelete)+"-")
+		if group, err := url.PathUnescape(snap.Group); err == nil {
+			snap.Group = group
+		}
+	default:
+		return snap, false
+	}
+
+	snap.Name = name
+	snap.CreatedAt = createdAt
+	return snap, true
+}
+
+// Policy tells how many snapshots are kept. Daily and Weekly keep the newest
+// daily snapshot of each of the last days and weeks that have one, PreDelete
+// keeps the newest pre-delete snapshots.
+type Policy struct {
+	Daily     int
+	Weekly    int
+	PreDelete int
+}
+
+func DefaultPolicy() Policy {
+	return Policy{Daily: 7, Weekly: 4, PreDelete: 20}
+}
+
+// PolicyFromEnv returns the default policy overridden by KEEP_SNAPSHOT_RETENTION,
+// a comma separated list such as "daily=7,weekly=4,pre-delete=20".
+func PolicyFromEnv() (Policy, error) {
+	policy := DefaultPolicy()
+
+	value := os.Getenv("KEEP_SNAPSHOT_RETENTION")
+	if value == "" {
+		return policy, nil
+	}
+
+	for _, rule := range strings.Split(value, ",") {
+		key, count, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok {
+			return policy, fmt.Errorf("invalid retention rule %q", rule)
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return policy, fmt.Errorf("invalid retention count %q", count)
+		}
+		switch Kind(key) {
+		case Daily:
+			policy.Daily = n
+		case "weekly":
+			policy.Weekly = n
+		case PreDelete:
+			policy.PreDelete = n
+		default:
+			return policy, fmt.Errorf("unknown retention rule %q", key)
+		}
+	}
+
+	return policy, nil
+}
+
+// Expired returns the snapshots the policy doesn't keep. The given snapshots
+// must be sorted newest first.
+func (p Policy) Expired(snaps []Snapshot) []Snapshot {
+	var (
+		expired   []Snapshot
+		days      = map[string]bool{}
+		weeks     = map[string]bool{}
+		preDelete = 0
+	)
+
+	for _, s := range snaps {
+		keep := false
+
+		switch s.Kind {
+		case Daily:
+			local := s.CreatedAt.Local()
+			day := local.Format(time.DateOnly)
+			year, w := local.ISOWeek()
+			week := fmt.Sprintf("%d-%d", year, w)
+
+			if !days[day] && len(days) < p.Daily {
+				days[day] = true
+				keep = true
+			}
+			if !weeks[week] && len(weeks) < p.Weekly {
+				weeks[week] = true
+				keep = true
+			}
+		case PreDelete:
+			preDelete++
+			keep = preDelete <= p.PreDelete
+		}
+
+		if !keep {
+			expired = append(expired, s)
+		}
+	}
+
+	return expired
+}
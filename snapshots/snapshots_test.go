@@ -0,0 +1,168 @@
+package snapshots_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/backup"
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/snapshots"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func TestPolicyExpired(t *testing.T) {
+	var (
+		snaps []snapshots.Snapshot
+		now   = time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	)
+	// one daily snapshot for each of the last 60 days, newest first
+	for i := 0; i < 60; i++ {
+		snaps = append(snaps, snapshots.Snapshot{
+			Name:      "daily" + now.AddDate(0, 0, -i).Format(time.DateOnly),
+			Kind:      snapshots.Daily,
+			CreatedAt: now.AddDate(0, 0, -i),
+		})
+	}
+	for i := 0; i < 5; i++ {
+		snaps = append(snaps, snapshots.Snapshot{
+			Name:      "pre-delete",
+			Kind:      snapshots.PreDelete,
+			CreatedAt: now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+
+	policy := snapshots.Policy{Daily: 7, Weekly: 4, PreDelete: 2}
+	expired := policy.Expired(snaps)
+
+	kept := len(snaps) - len(expired)
+	// 7 days span two weeks, so the weekly rule adds 2 older weeks, plus the 2
+	// newest pre-delete snapshots
+	if kept != 7+2+2 {
+		t.Fatalf("expected 11 snapshots kept, got %v", kept)
+	}
+	for _, s := range expired {
+		if s.Kind == snapshots.Daily && now.Sub(s.CreatedAt) < 7*24*time.Hour {
+			t.Fatalf("snapshot of the last 7 days expired: %v", s.Name)
+		}
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("KEEP_SNAPSHOT_RETENTION", "daily=3, weekly=0")
+	policy, err := snapshots.PolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Daily != 3 || policy.Weekly != 0 || policy.PreDelete != snapshots.DefaultPolicy().PreDelete {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+
+	t.Setenv("KEEP_SNAPSHOT_RETENTION", "monthly=3")
+	if _, err := snapshots.PolicyFromEnv(); err == nil {
+		t.Fatal("unknown rule accepted")
+	}
+}
+
+func TestRestoreDeletedGroup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	notes.BeforeDestroy = snapshots.BeforeDestroy
+	defer func() { notes.BeforeDestroy = nil }()
+
+	if _, err := notes.NewNoteFile("books", "books to read"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("books", "Programming Language Pragmatics"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.DeleteGroup("books"); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err := snapshots.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 || snaps[0].Kind != snapshots.PreDelete || snaps[0].Group != "books" {
+		t.Fatalf("unexpected snapshots %+v", snaps)
+	}
+
+	if err := snapshots.Restore(snaps[0].Name, backup.Merge); err != nil {
+		t.Fatal(err)
+	}
+	note, err := notes.GetNoteById("books", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(note.Text[:31]); got != "Programming Language Pragmatics" {
+		t.Fatalf("unexpected note %q", got)
+	}
+}
+
+func TestDeleteDespiteInvalidRetention(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KEEP_SNAPSHOT_RETENTION", "daily=seven")
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	var warnings []error
+	notes.BeforeDestroy = snapshots.BeforeDestroy
+	snapshots.Warn = func(err error) { warnings = append(warnings, err) }
+	defer func() { notes.BeforeDestroy, snapshots.Warn = nil, nil }()
+
+	if _, err := notes.NewNoteFile("books", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.AddNote("books", "Dune"); err != nil {
+		t.Fatal(err)
+	}
+	if err := notes.DeleteNoteById("books", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected the failed prune to be warned of, got %v", warnings)
+	}
+	if snaps, err := snapshots.List(); err != nil || len(snaps) != 1 {
+		t.Fatalf("expected the snapshot to be taken, got %v %v", snaps, err)
+	}
+}
+
+func TestNoSnapshotForMissingNote(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+	notes.BeforeDestroy = snapshots.BeforeDestroy
+	defer func() { notes.BeforeDestroy = nil }()
+
+	for _, name := range []string{"books", "movies"} {
+		if _, err := notes.NewNoteFile(name, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := notes.DeleteNoteById("books", 7); !errors.Is(err, notes.ErrNoteNotFound) {
+		t.Fatalf("expected ErrNoteNotFound, got %v", err)
+	}
+	if _, err := notes.MoveNote("books", 7, "movies", notes.MoveOptions{}); !errors.Is(err, notes.ErrNoteNotFound) {
+		t.Fatalf("expected ErrNoteNotFound, got %v", err)
+	}
+	if snaps, err := snapshots.List(); err != nil || len(snaps) != 0 {
+		t.Fatalf("expected no snapshots, got %+v %v", snaps, err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package style decides whether keep writes colors and which ones. Whether
+// colors are written is settled once, from the --color flag, NO_COLOR,
+// CLICOLOR_FORCE and whether the output is a terminal, and the colors of the
+// theme in use are shared by everything showing notes and groups.
+package style
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// Mode is when colors are written.
+type Mode int
+
+const (
+	Auto Mode = iota
+	Always
+	Never
+)
+
+var modeNames = []string{"auto", "always", "never"}
+
+func (m Mode) String() string {
+	return modeNames[m]
+}
+
+// ParseMode parses "auto", "always" or "never".
+func ParseMode(name string) (Mode, error) {
+	for m, n := range modeNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Mode(m), nil
+		}
+	}
+	return Auto, fmt.Errorf("unknown color mode %q, expected auto, always or never", name)
+}
+
+// Enabled reports whether colors are written in the given mode to an output
+// that is a terminal or not. In auto mode, NO_COLOR set to anything turns
+// colors off and CLICOLOR_FORCE set to anything but 0 turns them on;
+// otherwise colors are written to terminals only, dumb ones excluded.
+func Enabled(mode Mode, terminal bool) bool {
+	switch mode {
+	case Always:
+		return true
+	case Never:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+	return terminal && os.Getenv("TERM") != "dumb"
+}
+
+// Apply sets whether colors are written to the standard output.
+func Apply(mode Mode) {
+	color.NoColor = !Enabled(mode, term.IsTerminal(int(os.Stdout.Fd())))
+}
+
+// Colored reports whether colors are written.
+func Colored() bool {
+	return !color.NoColor
+}
+
+// Style is the attributes some text is written with, such as its color.
+type Style []color.Attribute
+
+// Sprint formats like fmt.Sprint, in the style when colors are written.
+func (s Style) Sprint(a ...any) string {
+	return s.paint(fmt.Sprint(a...))
+}
+
+// Sprintf formats like fmt.Sprintf, in the style when colors are written.
+func (s Style) Sprintf(format string, a ...any) string {
+	return s.paint(fmt.Sprintf(format, a...))
+}
+
+// Println writes a line to the standard output, in the style when colors are
+// written.
+func (s Style) Println(a ...any) {
+	fmt.Fprintln(color.Output, s.paint(strings.TrimSuffix(fmt.Sprintln(a...), "\n")))
+}
+
+func (s Style) paint(text string) string {
+	if !Colored() || len(s) == 0 {
+		return text
+	}
+	// color.New turns colors off on its own when NO_COLOR is set, which Apply
+	// already accounted for
+	c := color.New(s...)
+	c.EnableColor()
+	return c.Sprint(text)
+}
+
+// Theme holds the styles of the parts of notes and groups shown, other than
+// the colors of the notes themselves.
+type Theme struct {
+	Badge   Style // id or date shown before notes and groups
+	Heading Style // descriptions of groups and names of groups above their notes
+	Faint   Style // details such as the progress of groups and due dates
+	Alert   Style // due dates gone by
+}
+
+// Themes are the themes fitting a dark and a light terminal.
+var Themes = map[string]Theme{
+	"dark": {
+		Badge:   Style{color.BgHiBlue, color.Bold},
+		Heading: Style{color.FgHiWhite, color.Bold},
+		Faint:   Style{color.FgHiBlack},
+		Alert:   Style{color.FgHiRed},
+	},
+	"light": {
+		Badge:   Style{color.BgBlue, color.FgHiWhite, color.Bold},
+		Heading: Style{color.FgBlack, color.Bold},
+		Faint:   Style{color.FgHiBlack},
+		Alert:   Style{color.FgRed},
+	},
+}
+
+// Current is the theme in use.
+var Current = Themes["dark"]
+
+// Use makes the theme of the given name the one in use.
+func Use(name string) error {
+	t, ok := Themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, expected %s", name, strings.Join(ThemeNames(), " or "))
+	}
+	Current = t
+	return nil
+}
+
+// ThemeNames returns the names of the themes, sorted.
+func ThemeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+package style_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/style"
+	"github.com/fatih/color"
+)
+
+func TestParseMode(t *testing.T) {
+	for name, want := range map[string]style.Mode{"auto": style.Auto, "Always": style.Always, " never": style.Never} {
+		got, err := style.ParseMode(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %v, got %v", name, want, got)
+		}
+	}
+	if _, err := style.ParseMode("sometimes"); err == nil {
+		t.Fatal("expected an unknown mode to fail")
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	for _, c := range []struct {
+		mode     style.Mode
+		terminal bool
+		noColor  string
+		force    string
+		term     string
+		want     bool
+	}{
+		{style.Auto, true, "", "", "xterm", true},
+		{style.Auto, false, "", "", "xterm", false},
+		{style.Auto, true, "", "", "dumb", false},
+		{style.Auto, true, "1", "", "xterm", false},
+		{style.Auto, false, "", "1", "xterm", true},
+		{style.Auto, false, "", "0", "xterm", false},
+		{style.Auto, true, "1", "1", "xterm", false},
+		{style.Always, false, "1", "", "dumb", true},
+		{style.Never, true, "", "1", "xterm", false},
+	} {
+		t.Setenv("NO_COLOR", c.noColor)
+		t.Setenv("CLICOLOR_FORCE", c.force)
+		t.Setenv("TERM", c.term)
+		if got := style.Enabled(c.mode, c.terminal); got != c.want {
+			t.Fatalf("%+v: expected %v, got %v", c, c.want, got)
+		}
+	}
+}
+
+func TestUse(t *testing.T) {
+	defer style.Use("dark")
+	if err := style.Use("light"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(style.Current.Badge, style.Themes["light"].Badge) {
+		t.Fatal("expected the light theme to be in use")
+	}
+	if err := style.Use("blue"); err == nil {
+		t.Fatal("expected an unknown theme to fail")
+	}
+}
+
+func TestStyle(t *testing.T) {
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	s := style.Style{color.FgRed, color.Bold}
+
+	// NO_COLOR is left to Apply, for --color always to override it
+	t.Setenv("NO_COLOR", "1")
+	color.NoColor = false
+	if got := s.Sprintf("%v notes", 3); got != "\x1b[31;1m3 notes\x1b[0;22m" {
+		t.Fatalf("unexpected colored text %q", got)
+	}
+	color.NoColor = true
+	if got := s.Sprint("3 notes"); got != "3 notes" {
+		t.Fatalf("unexpected plain text %q", got)
+	}
+}
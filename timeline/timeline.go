@@ -0,0 +1,196 @@
+// Package timeline shows when notes were taken: as a heatmap of the notes
+// added each day, the way code hosts show contributions, or as a list of the
+// notes of all groups under the day, week or month they were added on.
+package timeline
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+// Period is the span of time notes are grouped by.
+type Period int
+
+const (
+	Day Period = iota
+	Week
+	Month
+)
+
+// ParsePeriod parses "day", "week" or "month".
+func ParsePeriod(name string) (Period, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "day":
+		return Day, nil
+	case "week":
+		return Week, nil
+	case "month":
+		return Month, nil
+	}
+	return Day, fmt.Errorf("unknown period %q, expected day, week or month", name)
+}
+
+// Start returns the start of the period t falls in, in the location of t.
+// Weeks start on monday.
+func Start(t time.Time, p Period) time.Time {
+	y, m, d := t.Date()
+	switch p {
+	case Week:
+		day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Entry is a note of the timeline with the group it is in.
+type Entry struct {
+	Group string
+	Note  notes.Note
+}
+
+// CreatedAt returns when the note was added, in the location of loc.
+func (e Entry) CreatedAt(loc *time.Location) time.Time {
+	return time.UnixMilli(e.Note.CreatedAt).In(loc)
+}
+
+// Entries flattens the notes of several groups, keyed by group name, into
+// entries sorted by the time they were added.
+func Entries(found map[string][]notes.Note) []Entry {
+	var entries []Entry
+	for group, groupNotes := range found {
+		for _, n := range groupNotes {
+			entries = append(entries, Entry{Group: group, Note: n})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Note.CreatedAt != b.Note.CreatedAt {
+			return a.Note.CreatedAt < b.Note.CreatedAt
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Note.Id < b.Note.Id
+	})
+	return entries
+}
+
+// Count returns how many entries were added in each period, keyed by the
+// start of the period in the location of loc.
+func Count(entries []Entry, p Period, loc *time.Location) map[time.Time]int {
+	counts := map[time.Time]int{}
+	for _, e := range entries {
+		counts[Start(e.CreatedAt(loc), p)]++
+	}
+	return counts
+}
+
+// shades are the cells of the heatmap, from days without notes to the days
+// with the most.
+var shades = []string{"·", "░", "▒", "▓", "█"}
+
+// Heatmap writes a grid of the notes added each day of the given number of
+// weeks up to end, a column per week and a row per weekday.
+func Heatmap(w io.Writer, entries []Entry, end time.Time, weeks int) {
+	loc := end.Location()
+	counts := Count(entries, Day, loc)
+	first := Start(end, Week).AddDate(0, 0, -7*(weeks-1))
+	end = Start(end, Day)
+
+	most, total := 0, 0
+	for day, count := range counts {
+		if !day.Before(first) && !day.After(end) {
+			most = max(most, count)
+			total += count
+		}
+	}
+
+	// month names above the week they start in, as long as they fit
+	labels := []rune(strings.Repeat(" ", weeks+3))
+	free := 0
+	for col := 0; col < weeks; col++ {
+		week := first.AddDate(0, 0, 7*col)
+		if col > 0 && week.AddDate(0, 0, -7).Month() == week.Month() || col < free {
+			continue
+		}
+		copy(labels[col:], []rune(week.Format("Jan")))
+		free = col + 4
+	}
+	fmt.Fprintf(w, "    %s\n", strings.TrimRight(string(labels), " "))
+
+	for row := 0; row < 7; row++ {
+		var line strings.Builder
+		switch row {
+		case 0:
+			line.WriteString("Mon ")
+		case 2:
+			line.WriteString("Wed ")
+		case 4:
+			line.WriteString("Fri ")
+		default:
+			line.WriteString("    ")
+		}
+		for col := 0; col < weeks; col++ {
+			day := first.AddDate(0, 0, 7*col+row)
+			if day.After(end) {
+				break
+			}
+			line.WriteString(shade(counts[day], most))
+		}
+		fmt.Fprintln(w, line.String())
+	}
+
+	fmt.Fprintf(w, "%v notes in the last %v weeks   less %s more\n", total, weeks, strings.Join(shades, " "))
+}
+
+// shade returns the cell of a day with count notes, out of most at most.
+func shade(count, most int) string {
+	if count == 0 {
+		return shades[0]
+	}
+	levels := len(shades) - 1
+	return shades[(count*levels+most-1)/most]
+}
+
+// List writes the entries in the order given under the period they were
+// added in, in the location of loc.
+func List(w io.Writer, entries []Entry, p Period, loc *time.Location) {
+	var current time.Time
+	for _, e := range entries {
+		created := e.CreatedAt(loc)
+		if start := Start(created, p); !start.Equal(current) {
+			if !current.IsZero() {
+				fmt.Fprintln(w)
+			}
+			current = start
+			fmt.Fprintln(w, heading(start, p))
+		}
+		text := strings.Join(strings.Fields(e.Note.String()), " ")
+		fmt.Fprintf(w, "  %s  %s %v  %s\n", created.Format(entryLayouts[p]), e.Group, e.Note.Id, text)
+	}
+}
+
+// entryLayouts are the layouts of the time of the entries listed under each
+// period.
+var entryLayouts = map[Period]string{
+	Day:   "15:04",
+	Week:  "Mon 15:04",
+	Month: "Jan 02 15:04",
+}
+
+func heading(start time.Time, p Period) string {
+	switch p {
+	case Week:
+		return "week of " + start.Format("Mon Jan 2 2006")
+	case Month:
+		return start.Format("January 2006")
+	}
+	return start.Format("Mon Jan 2 2006")
+}
@@ -0,0 +1,98 @@
+package timeline_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/timeline"
+)
+
+func note(id int64, text string, created time.Time) notes.Note {
+	return notes.NewNote(id, text, 0, created.UnixMilli())
+}
+
+func entries() []timeline.Entry {
+	return timeline.Entries(map[string][]notes.Note{
+		"books": {
+			note(1, "Crafting Interpreters", time.Date(2026, 9, 28, 10, 0, 0, 0, time.UTC)),
+			note(2, "Dune", time.Date(2026, 10, 14, 8, 30, 0, 0, time.UTC)),
+		},
+		"work": {
+			note(1, "Fix\nthe build", time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)),
+			note(2, "Standup", time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)),
+			note(3, "Retro", time.Date(2026, 10, 15, 17, 0, 0, 0, time.UTC)),
+		},
+	})
+}
+
+func TestStart(t *testing.T) {
+	// a thursday
+	at := time.Date(2026, 10, 15, 14, 30, 0, 0, time.UTC)
+	for p, want := range map[timeline.Period]time.Time{
+		timeline.Day:   time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+		timeline.Week:  time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		timeline.Month: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if got := timeline.Start(at, p); !got.Equal(want) {
+			t.Fatalf("%v: expected %v, got %v", p, want, got)
+		}
+	}
+	sunday := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	if got := timeline.Start(sunday, timeline.Week); got.Day() != 12 {
+		t.Fatalf("expected sundays to end the week, got %v", got)
+	}
+}
+
+func TestHeatmap(t *testing.T) {
+	var buf bytes.Buffer
+	timeline.Heatmap(&buf, entries(), time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 4)
+	want := `    Sep
+Mon ·▒··
+    ····
+Wed ···█
+    ···▒
+Fri ····
+    ···
+    ···
+5 notes in the last 4 weeks   less · ░ ▒ ▓ █ more
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected heatmap:\n%s\nexpected:\n%s", buf.String(), want)
+	}
+}
+
+func TestList(t *testing.T) {
+	var buf bytes.Buffer
+	timeline.List(&buf, entries(), timeline.Day, time.UTC)
+	want := `Mon Sep 28 2026
+  10:00  books 1  Crafting Interpreters
+
+Wed Oct 14 2026
+  08:30  books 2  Dune
+  09:00  work 1  Fix the build
+  09:00  work 2  Standup
+
+Thu Oct 15 2026
+  17:00  work 3  Retro
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected list:\n%s\nexpected:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	timeline.List(&buf, entries(), timeline.Month, time.UTC)
+	want = `September 2026
+  Sep 28 10:00  books 1  Crafting Interpreters
+
+October 2026
+  Oct 14 08:30  books 2  Dune
+  Oct 14 09:00  work 1  Fix the build
+  Oct 14 09:00  work 2  Standup
+  Oct 15 17:00  work 3  Retro
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected list:\n%s\nexpected:\n%s", buf.String(), want)
+	}
+}
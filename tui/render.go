@@ -0,0 +1,164 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/style"
+)
+
+const (
+	reset   = "\x1b[0m"
+	bold    = "\x1b[1m"
+	dim     = "\x1b[2m"
+	reverse = "\x1b[7m"
+)
+
+// render returns the escape sequences drawing the whole screen of the given
+// size.
+func (m *model) render(width, height int) string {
+	width, height = max(width, 20), max(height, 3)
+	rows := height - 2
+
+	sidebarWidth := min(24, width/3)
+	notesWidth := width - sidebarWidth - 1
+
+	lines := make([]string, 0, height)
+	lines = append(lines, reverse+bold+fit(" keep", width)+reset)
+
+	groupsFrom := scroll(m.group, rows)
+	visible := m.visible()
+	notesFrom := scroll(m.note, rows)
+
+	for row := 0; row < rows; row++ {
+		var b strings.Builder
+
+		if i := groupsFrom + row; i < len(m.groups) {
+			g := m.groups[i]
+			title := " " + g.name
+			if g.header.Encrypted() {
+				title += " 🔒"
+			}
+			title = fmt.Sprintf("%s %v", title, g.header.Size)
+			if i == m.group {
+				if m.focus == sidebarPane && m.mode != searching {
+					b.WriteString(reverse)
+				}
+				b.WriteString(bold)
+			}
+			b.WriteString(fit(title, sidebarWidth))
+		} else {
+			b.WriteString(fit("", sidebarWidth))
+		}
+		b.WriteString(reset + dim + "│" + reset)
+
+		if i := notesFrom + row; i < len(visible) {
+			n := visible[i]
+			text := strings.ReplaceAll(n.String(), "\n", " ")
+			if n.Pinned {
+				text = "📌 " + text
+			}
+			if style.Colored() {
+				fmt.Fprintf(&b, "\x1b[%vm", n.DisplayColor())
+			}
+			b.WriteString(bold)
+			if i == m.note && m.focus == notesPane {
+				b.WriteString(reverse)
+			}
+			b.WriteString(fit(fmt.Sprintf(" %3d  %s", n.Id, text), notesWidth))
+		} else if row == 0 && len(m.groups) > 0 {
+			empty := " no notes"
+			if m.search != "" {
+				empty = " no notes match"
+			}
+			b.WriteString(dim + fit(empty, notesWidth))
+		} else {
+			b.WriteString(fit("", notesWidth))
+		}
+		b.WriteString(reset)
+
+		lines = append(lines, b.String())
+	}
+
+	bar, style, cursor := m.bar()
+	lines = append(lines, style+fit(bar, width)+reset)
+
+	frame := "\x1b[?25l\x1b[H" + strings.Join(lines, "\r\n")
+	if cursor >= 0 {
+		frame += fmt.Sprintf("\x1b[%v;%vH\x1b[?25h", height, min(cursor+1, width))
+	}
+	return frame
+}
+
+// bar returns the bottom line of the screen, its style and the column of the
+// cursor on it, or -1 when there is nothing to type.
+func (m *model) bar() (string, string, int) {
+	switch m.mode {
+	case searching:
+		line := "/" + m.search
+		return line, "", textWidth(line)
+	case editing:
+		prompt := "add: "
+		if m.editing != 0 {
+			prompt = fmt.Sprintf("edit %v: ", m.editing)
+		}
+		return prompt + string(m.input), "", textWidth(prompt) + textWidth(string(m.input[:m.cursor]))
+	case confirming:
+		g := m.groups[m.group]
+		return fmt.Sprintf("delete group %s and its %v notes? (y/n)", g.name, g.header.Size), bold, -1
+	}
+	if m.status != "" {
+		return m.status, "", -1
+	}
+	if m.search != "" {
+		return fmt.Sprintf("/%s  (esc to clear)", m.search), "", -1
+	}
+	return help, dim, -1
+}
+
+// scroll returns the first row to show so that the selected one is visible.
+func scroll(selected, rows int) int {
+	return max(selected-rows+1, 0)
+}
+
+// fit truncates or pads s with spaces to fill exactly the given columns.
+func fit(s string, columns int) string {
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := runeWidth(r)
+		if used+w > columns {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	b.WriteString(strings.Repeat(" ", columns-used))
+	return b.String()
+}
+
+func textWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// runeWidth returns the columns taken by r, telling wide characters such as
+// emojis and CJK apart from the rest.
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1faff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyEsc
+	keyCtrlC
+)
+
+type key struct {
+	code keyCode
+	r    rune // typed rune, for keyRune
+}
+
+// escapes are the sequences sent by the keys we handle that aren't runes.
+var escapes = map[string]keyCode{
+	"\x1b[A":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1b[C":  keyRight,
+	"\x1b[D":  keyLeft,
+	"\x1bOA":  keyUp,
+	"\x1bOB":  keyDown,
+	"\x1bOC":  keyRight,
+	"\x1bOD":  keyLeft,
+	"\x1b[H":  keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1b[1~": keyHome,
+	"\x1b[4~": keyEnd,
+	"\x1b[3~": keyDelete,
+}
+
+// parseKeys splits what was read from the terminal into keys. Unknown escape
+// sequences are dropped.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) == 1 {
+				keys = append(keys, key{code: keyEsc})
+				break
+			}
+			n := escapeLen(b)
+			if code, ok := escapes[string(b[:n])]; ok {
+				keys = append(keys, key{code: code})
+			}
+			b = b[n:]
+			continue
+		}
+
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		switch r {
+		case '\r', '\n':
+			keys = append(keys, key{code: keyEnter})
+		case '\t':
+			keys = append(keys, key{code: keyTab})
+		case 0x7f, 0x08:
+			keys = append(keys, key{code: keyBackspace})
+		case 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				keys = append(keys, key{code: keyRune, r: r})
+			}
+		}
+	}
+	return keys
+}
+
+// escapeLen returns the length of the escape sequence b starts with.
+func escapeLen(b []byte) int {
+	if b[1] != '[' && b[1] != 'O' {
+		return 2
+	}
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// terminal is the terminal the interface is drawn on, in raw mode and on the
+// alternate screen while the interface runs.
+type terminal struct {
+	fd    int
+	state *term.State
+}
+
+func openTerminal() (*terminal, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, fmt.Errorf("keep tui must be run in a terminal")
+	}
+	t := &terminal{fd: fd}
+	return t, t.resume()
+}
+
+// suspend gives the terminal back as it was, e.g. to prompt for a passphrase.
+func (t *terminal) suspend() {
+	fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+	term.Restore(t.fd, t.state)
+}
+
+func (t *terminal) resume() error {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return err
+	}
+	t.state = state
+	fmt.Fprint(os.Stdout, "\x1b[?1049h")
+	return nil
+}
+
+func (t *terminal) size() (int, int) {
+	w, h, err := term.GetSize(t.fd)
+	if err != nil {
+		return 80, 24
+	}
+	return w, h
+}
+
+func (t *terminal) readKeys() ([]key, error) {
+	buf := make([]byte, 256)
+	n, err := os.Stdin.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeys(buf[:n]), nil
+}
@@ -0,0 +1,386 @@
+// Package tui is a full-screen terminal interface to browse and edit notes.
+//
+// Groups are listed in a sidebar and the notes of the selected group next to
+// it, colored by their color. Notes are filtered as a search term is typed,
+// added and edited in an inline editor, and groups are only deleted once
+// confirmed.
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DavidEsdrs/keep/notes"
+)
+
+type pane int
+
+const (
+	sidebarPane pane = iota
+	notesPane
+)
+
+type mode int
+
+const (
+	browsing mode = iota
+	searching
+	editing
+	confirming
+)
+
+const help = "↑↓ move  ⇥ switch  / search  a add  e edit  d delete  r reload  q quit"
+
+type group struct {
+	name   string
+	header notes.NoteFileHeader
+}
+
+// model is the state of the interface. Keys are applied to it by update and it
+// is drawn by render, so it can be driven without a terminal.
+type model struct {
+	groups []group
+	group  int // index of the selected group
+	notes  []notes.Note
+	note   int // index of the selected note among the visible ones
+	focus  pane
+	mode   mode
+
+	search string
+
+	input   []rune // text being edited
+	cursor  int
+	editing int64 // id of the note being edited, 0 when adding one
+
+	status string // result of the last action
+	quit   bool
+}
+
+// Run shows the interface until it is quit.
+func Run() error {
+	t, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer t.suspend()
+
+	// passphrases of encrypted groups are asked for on the regular screen
+	passphrase := notes.Passphrase
+	if passphrase != nil {
+		notes.Passphrase = func(group string) (string, error) {
+			t.suspend()
+			defer t.resume()
+			return passphrase(group)
+		}
+		defer func() { notes.Passphrase = passphrase }()
+	}
+
+	m := &model{}
+	m.load()
+	for !m.quit {
+		fmt.Print(m.render(t.size()))
+		keys, err := t.readKeys()
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			m.update(k)
+		}
+	}
+	return nil
+}
+
+// load reads the groups of the store again, keeping the selected one.
+func (m *model) load() {
+	selected := m.groupName()
+
+	names, err := notes.GroupNames()
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+
+	m.groups = m.groups[:0]
+	m.group = 0
+	for _, name := range names {
+		header, err := notes.GetGroupHeader(name)
+		if err != nil {
+			continue
+		}
+		if name == selected {
+			m.group = len(m.groups)
+		}
+		m.groups = append(m.groups, group{name: name, header: header})
+	}
+
+	m.loadNotes()
+}
+
+// loadNotes reads the notes of the selected group again.
+func (m *model) loadNotes() {
+	m.notes = nil
+	name := m.groupName()
+	if name == "" {
+		return
+	}
+	ch, err := notes.ReadAllNotes(name)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	for n := range ch {
+		m.notes = append(m.notes, n)
+	}
+	notes.Sort(m.notes, notes.SortById)
+	m.note = min(m.note, max(len(m.visible())-1, 0))
+}
+
+func (m *model) groupName() string {
+	if m.group >= len(m.groups) {
+		return ""
+	}
+	return m.groups[m.group].name
+}
+
+// visible returns the notes of the selected group matching the search term.
+func (m *model) visible() []notes.Note {
+	if m.search == "" {
+		return m.notes
+	}
+	term := strings.ToLower(m.search)
+	var result []notes.Note
+	for _, n := range m.notes {
+		if strings.Contains(strings.ToLower(n.String()), term) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (m *model) selectedNote() (notes.Note, bool) {
+	visible := m.visible()
+	if m.note >= len(visible) {
+		return notes.Note{}, false
+	}
+	return visible[m.note], true
+}
+
+func (m *model) update(k key) {
+	if k.code == keyCtrlC {
+		m.quit = true
+		return
+	}
+	switch m.mode {
+	case browsing:
+		m.browse(k)
+	case searching:
+		m.typeSearch(k)
+	case editing:
+		m.edit(k)
+	case confirming:
+		m.confirm(k)
+	}
+}
+
+func (m *model) browse(k key) {
+	m.status = ""
+
+	switch k.code {
+	case keyUp:
+		m.move(-1)
+	case keyDown:
+		m.move(1)
+	case keyLeft:
+		m.focus = sidebarPane
+	case keyRight:
+		m.focus = notesPane
+	case keyTab:
+		m.focus = 1 - m.focus
+	case keyEsc:
+		m.search = ""
+	case keyEnter:
+		if m.focus == sidebarPane {
+			m.focus = notesPane
+		} else {
+			m.startEdit()
+		}
+	case keyRune:
+		switch k.r {
+		case 'q':
+			m.quit = true
+		case 'k':
+			m.move(-1)
+		case 'j':
+			m.move(1)
+		case 'h':
+			m.focus = sidebarPane
+		case 'l':
+			m.focus = notesPane
+		case '/':
+			m.mode = searching
+			m.focus = notesPane
+		case 'a':
+			m.startAdd()
+		case 'e':
+			m.startEdit()
+		case 'd':
+			m.delete()
+		case 'r':
+			m.load()
+		}
+	}
+}
+
+func (m *model) move(delta int) {
+	if m.focus == sidebarPane {
+		i := m.group + delta
+		if i >= 0 && i < len(m.groups) {
+			m.group = i
+			m.note = 0
+			m.loadNotes()
+		}
+		return
+	}
+	i := m.note + delta
+	if i >= 0 && i < len(m.visible()) {
+		m.note = i
+	}
+}
+
+func (m *model) typeSearch(k key) {
+	switch k.code {
+	case keyRune:
+		m.search += string(k.r)
+		m.note = 0
+	case keyBackspace:
+		if r := []rune(m.search); len(r) > 0 {
+			m.search = string(r[:len(r)-1])
+			m.note = 0
+		}
+	case keyEnter:
+		m.mode = browsing
+	case keyEsc:
+		m.search = ""
+		m.mode = browsing
+	}
+}
+
+func (m *model) startAdd() {
+	if m.groupName() == "" {
+		m.status = "there is no group to add notes to"
+		return
+	}
+	m.mode = editing
+	m.editing = 0
+	m.input = nil
+	m.cursor = 0
+}
+
+func (m *model) startEdit() {
+	n, ok := m.selectedNote()
+	if !ok {
+		return
+	}
+	m.mode = editing
+	m.editing = n.Id
+	m.input = []rune(n.String())
+	m.cursor = len(m.input)
+}
+
+func (m *model) edit(k key) {
+	switch k.code {
+	case keyRune:
+		if len(m.input) >= len(notes.Note{}.Text) {
+			m.status = "notes are limited to 300 characters"
+			return
+		}
+		m.input = append(m.input[:m.cursor], append([]rune{k.r}, m.input[m.cursor:]...)...)
+		m.cursor++
+	case keyBackspace:
+		if m.cursor > 0 {
+			m.input = append(m.input[:m.cursor-1], m.input[m.cursor:]...)
+			m.cursor--
+		}
+	case keyDelete:
+		if m.cursor < len(m.input) {
+			m.input = append(m.input[:m.cursor], m.input[m.cursor+1:]...)
+		}
+	case keyLeft:
+		m.cursor = max(m.cursor-1, 0)
+	case keyRight:
+		m.cursor = min(m.cursor+1, len(m.input))
+	case keyHome:
+		m.cursor = 0
+	case keyEnd:
+		m.cursor = len(m.input)
+	case keyEsc:
+		m.mode = browsing
+		m.status = ""
+	case keyEnter:
+		m.mode = browsing
+		m.save()
+	}
+}
+
+func (m *model) save() {
+	text := strings.TrimSpace(string(m.input))
+	if text == "" {
+		m.status = "empty notes aren't saved"
+		return
+	}
+
+	name := m.groupName()
+	if m.editing == 0 {
+		if err := notes.AddNote(name, text); err != nil {
+			m.status = err.Error()
+			return
+		}
+		m.search = ""
+		m.load()
+		m.note = max(len(m.notes)-1, 0)
+		m.focus = notesPane
+		m.status = "note added"
+		return
+	}
+
+	if _, err := notes.EditNote(name, m.editing, text); err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.load()
+	m.status = fmt.Sprintf("note %v edited", m.editing)
+}
+
+func (m *model) delete() {
+	if m.focus == sidebarPane {
+		if m.groupName() != "" {
+			m.mode = confirming
+		}
+		return
+	}
+	n, ok := m.selectedNote()
+	if !ok {
+		return
+	}
+	if err := notes.DeleteNoteById(m.groupName(), n.Id); err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.load()
+	m.status = fmt.Sprintf("note %v deleted", n.Id)
+}
+
+func (m *model) confirm(k key) {
+	m.mode = browsing
+	if k.code != keyRune || (k.r != 'y' && k.r != 'Y') {
+		m.status = ""
+		return
+	}
+	name := m.groupName()
+	if err := notes.DeleteGroup(name); err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.load()
+	m.status = fmt.Sprintf("group %s deleted", name)
+}
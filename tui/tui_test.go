@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/DavidEsdrs/keep/notes"
+	"github.com/DavidEsdrs/keep/utils"
+)
+
+func setupStore(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	kfp, err := utils.GetKeepFilePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(kfp, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// press applies to the model the keys sent by a terminal for s
+func press(m *model, s string) {
+	for _, k := range parseKeys([]byte(s)) {
+		m.update(k)
+	}
+}
+
+func texts(m *model) []string {
+	var result []string
+	for _, n := range m.visible() {
+		result = append(result, n.String())
+	}
+	return result
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[B\x1b[3~é\r\x7f\x1b"))
+	want := []key{{code: keyRune, r: 'a'}, {code: keyDown}, {code: keyDelete}, {code: keyRune, r: 'é'}, {code: keyEnter}, {code: keyBackspace}, {code: keyEsc}}
+	if len(keys) != len(want) {
+		t.Fatalf("expected %v keys, got %v", len(want), keys)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("unexpected key %v: %v", i, keys[i])
+		}
+	}
+}
+
+func TestBrowseAndEdit(t *testing.T) {
+	setupStore(t)
+	for _, group := range []string{"books", "movies"} {
+		if _, err := notes.NewNoteFile(group, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, text := range []string{"Crafting Interpreters", "The Go Programming Language"} {
+		if err := notes.AddNote("books", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &model{}
+	m.load()
+	if len(m.groups) != 2 || m.groupName() != "books" || len(m.notes) != 2 {
+		t.Fatalf("unexpected groups %v and notes %v", m.groups, texts(m))
+	}
+	screen := m.render(80, 10)
+	if !strings.Contains(screen, "movies") || !strings.Contains(screen, "Crafting Interpreters") {
+		t.Fatalf("groups and notes not drawn: %q", screen)
+	}
+
+	// incremental search
+	press(m, "/go")
+	if got := texts(m); len(got) != 1 || got[0] != "The Go Programming Language" {
+		t.Fatalf("unexpected search results %q", got)
+	}
+	press(m, "\x1b")
+	if len(m.visible()) != 2 {
+		t.Fatal("search not cleared")
+	}
+
+	press(m, "aDune\r")
+	press(m, "e\x1b[HThe \r")
+	want := []string{"Crafting Interpreters", "The Go Programming Language", "The Dune"}
+	if got := texts(m); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected notes after add and edit %q", got)
+	}
+
+	press(m, "kd")
+	if got := texts(m); len(got) != 2 || got[1] != "The Dune" {
+		t.Fatalf("unexpected notes after delete %q", got)
+	}
+
+	// groups are deleted only once confirmed
+	press(m, "hjd")
+	if m.mode != confirming {
+		t.Fatal("expected a confirmation")
+	}
+	press(m, "n")
+	if names, _ := notes.GroupNames(); len(names) != 2 {
+		t.Fatalf("group deleted without confirmation: %v", names)
+	}
+	press(m, "dy")
+	if names, _ := notes.GroupNames(); len(names) != 1 || names[0] != "books" {
+		t.Fatalf("unexpected groups after delete %v", names)
+	}
+	if m.groupName() != "books" {
+		t.Fatalf("expected books to be selected, got %q", m.groupName())
+	}
+}
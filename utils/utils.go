@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
-	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
@@ -13,30 +12,26 @@ import (
 	"github.com/fatih/color"
 )
 
-func RandomColor() color.Attribute {
-	randomN := rand.Intn(5)
+// Colors are the foreground colors notes can be given, by name.
+var Colors = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+	"gray":    color.FgHiBlack,
+}
 
-	switch randomN {
-	case 1:
-		return color.FgRed
-	case 2:
-		return color.FgGreen
-	case 3:
-		return color.FgBlue
-	case 4:
-		return color.FgYellow
-	case 5:
-		return color.FgBlack
-	case 6:
-		return color.FgWhite
-	case 7:
-		return color.FgCyan
-	case 8:
-		return color.FgMagenta
-	default:
-		return color.FgCyan
+// ParseColor returns the foreground color of the given name.
+func ParseColor(name string) (color.Attribute, error) {
+	c, ok := Colors[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unknown color %q", name)
 	}
-
+	return c, nil
 }
 
 func showError(text string, errCode int) {
@@ -64,13 +59,50 @@ func ExtractExtension(filename string) string {
 	return segs[len(segs)-1]
 }
 
+// DefaultProfile is the profile in use unless another one is chosen.
+const DefaultProfile = "default"
+
+// Profile is the name of the profile in use. Each profile keeps its notes in
+// a store of its own.
+var Profile = DefaultProfile
+
+// StorePath, when set, is the directory the files from keep are stored in
+// instead of the default store of Profile.
+var StorePath string
+
 // return the directory in which the files from keep must be stored
 func GetKeepFilePath() (string, error) {
+	if StorePath != "" {
+		return StorePath, nil
+	}
+	return DefaultStorePath(Profile)
+}
+
+// DefaultStorePath returns the directory the files of a profile are stored in
+// unless told otherwise: ~/.keep for the default profile and ~/.keep-<profile>
+// for the others.
+func DefaultStorePath(profile string) (string, error) {
 	homerDir, err := os.UserHomeDir()
 	keepFolder := path.Join(homerDir, ".keep")
+	if profile != DefaultProfile {
+		keepFolder += "-" + profile
+	}
 	return keepFolder, err
 }
 
+// ExpandHome replaces a leading ~ of a path with the home directory.
+func ExpandHome(p string) string {
+	rest, ok := strings.CutPrefix(p, "~")
+	if !ok || rest != "" && !strings.HasPrefix(rest, "/") {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return path.Join(home, rest)
+}
+
 func DoesFileExists(filePath string) bool {
 	_, error := os.Stat(filePath)
 	return !errors.Is(error, os.ErrNotExist)
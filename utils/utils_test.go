@@ -71,3 +71,20 @@ func TestOpenOrCreate(t *testing.T) {
 		defer f.Close()
 	})
 }
+
+func TestDefaultStorePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for profile, want := range map[string]string{
+		utils.DefaultProfile: home + "/.keep",
+		"work":               home + "/.keep-work",
+	} {
+		got, err := utils.DefaultStorePath(profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("%s: expected %q, got %q", profile, want, got)
+		}
+	}
+}